@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+const (
+	archetypeGoal = "archetype:generate"
+	// archetypeRepositoryGoal is used when the archetype has a remote repository,
+	// the archetypeRepository property is only supported by maven-archetype-plugin 2.x
+	archetypeRepositoryGoal = "org.apache.maven.plugins:maven-archetype-plugin:2.4:generate"
+)
+
+// addCodeMavenArchetype generates a project using a maven archetype
+// and make a push with that code to a gogs repository
+func addCodeMavenArchetype(rep Repository, gogs InitData, host string, l *log.Logger) error {
+	a := rep.MavenArchetype
+	l.Printf("generating project with %v:%v:%v archetype\n", a.GroupID, a.ArtifactID, a.Version)
+	err := validator.New().Struct(a)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", rep.Name)
+	if err != nil {
+		return err
+	}
+
+	cmd := newCMD(l, "mvn", archetypeArgs(a)...)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("error: %v output: %v", err.Error(), out.String())
+	}
+	// create repository
+	dir = filepath.Join(dir, archetypeOutputDir(a))
+	return createRepo(rep, gogs, host, dir, l)
+}
+
+// archetypeArgs returns the mvn arguments to generate a project with the archetype
+func archetypeArgs(a MavenArchetype) []string {
+	args := []string{archetypeGoal}
+	if a.Repository != "" {
+		args = []string{archetypeRepositoryGoal, "-DarchetypeRepository=" + a.Repository}
+	}
+	args = append(args,
+		"-DarchetypeGroupId="+a.GroupID,
+		"-DarchetypeArtifactId="+a.ArtifactID,
+		"-DarchetypeVersion="+a.Version)
+
+	keys := []string{}
+	for k := range a.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-D"+k+"="+a.Properties[k])
+	}
+	return append(args, "-DinteractiveMode=false")
+}
+
+// archetypeOutputDir returns the directory generated by the archetype
+func archetypeOutputDir(a MavenArchetype) string {
+	if a.OutputDir != "" {
+		return a.OutputDir
+	}
+	return a.Properties["artifactId"]
+}
+
+// dantaArchetype returns the maven archetype preset for Danta AEM projects
+func dantaArchetype(d DantaAEM) MavenArchetype {
+	a := MavenArchetype{
+		GroupID:    d.ArchetypeGroup,
+		ArtifactID: d.ArchetypeArtifact,
+		Version:    d.ArchetypeVersion,
+		Properties: map[string]string{
+			"groupId":          d.GroupID,
+			"artifactId":       d.ArtifactID,
+			"project-app-name": d.AppName,
+			"package":          d.Package,
+			"cq-server":        d.AEMServer,
+			"nexus-public-url": d.NexusURL,
+		},
+		OutputDir: d.AppName,
+	}
+	if a.GroupID == "" {
+		a.GroupID = "io.tikaltechnologies.danta"
+	}
+	if a.ArtifactID == "" {
+		a.ArtifactID = "danta-aem-archetype"
+	}
+	return a
+}
+
+// bloomreachArchetype returns the maven archetype preset for Bloomreach projects
+func bloomreachArchetype(b BR) MavenArchetype {
+	a := MavenArchetype{
+		GroupID:    "org.onehippo.cms7",
+		ArtifactID: "hippo-project-archetype",
+		Version:    b.ArchetypeVersion,
+		Repository: "https://maven.onehippo.com/maven2",
+		Properties: map[string]string{
+			"groupId":     b.GroupID,
+			"artifactId":  b.ArtifactID,
+			"version":     b.Version,
+			"package":     b.Package,
+			"projectName": b.ProjectName,
+		},
+		OutputDir: b.ArtifactID,
+	}
+	if a.Version == "" {
+		a.Version = "12.2.0"
+	}
+	return a
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArchetypeArgs(t *testing.T) {
+	a := MavenArchetype{
+		GroupID:    "org.example",
+		ArtifactID: "example-archetype",
+		Version:    "1.0.0",
+		Properties: map[string]string{"package": "com.example", "artifactId": "example"},
+	}
+	want := []string{
+		"archetype:generate",
+		"-DarchetypeGroupId=org.example",
+		"-DarchetypeArtifactId=example-archetype",
+		"-DarchetypeVersion=1.0.0",
+		"-DartifactId=example",
+		"-Dpackage=com.example",
+		"-DinteractiveMode=false",
+	}
+	if got := archetypeArgs(a); !reflect.DeepEqual(got, want) {
+		t.Errorf("archetypeArgs got: %v want: %v", got, want)
+	}
+	if dir := archetypeOutputDir(a); dir != "example" {
+		t.Errorf("output dir must default to the artifactId got: %v", dir)
+	}
+
+	a.Repository = "https://repo.example.com/maven2"
+	args := archetypeArgs(a)
+	if args[0] != archetypeRepositoryGoal || args[1] != "-DarchetypeRepository=https://repo.example.com/maven2" {
+		t.Errorf("repository must use the 2.x plugin goal got: %v", args[:2])
+	}
+}
+
+func TestArchetypePresets(t *testing.T) {
+	danta := dantaArchetype(DantaAEM{ArchetypeVersion: "1.0.0", AppName: "app"})
+	if danta.GroupID != "io.tikaltechnologies.danta" || danta.ArtifactID != "danta-aem-archetype" {
+		t.Errorf("danta preset must use default coordinates got: %v:%v", danta.GroupID, danta.ArtifactID)
+	}
+	if danta.OutputDir != "app" {
+		t.Errorf("danta output dir must be the app name got: %v", danta.OutputDir)
+	}
+	danta = dantaArchetype(DantaAEM{ArchetypeGroup: "com.custom", ArchetypeArtifact: "custom-archetype"})
+	if danta.GroupID != "com.custom" || danta.ArtifactID != "custom-archetype" {
+		t.Errorf("danta preset must honor the configured coordinates got: %v:%v", danta.GroupID, danta.ArtifactID)
+	}
+
+	br := bloomreachArchetype(BR{ArtifactID: "myCompany"})
+	if br.Version != "12.2.0" || br.Repository == "" {
+		t.Errorf("bloomreach preset must use default version and repository got: %v %v", br.Version, br.Repository)
+	}
+	br = bloomreachArchetype(BR{ArchetypeVersion: "13.0.0"})
+	if br.Version != "13.0.0" {
+		t.Errorf("bloomreach preset must honor the archetype version got: %v", br.Version)
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// defaultDownloadTimeout is the time in seconds to download an archive
+const defaultDownloadTimeout = 600
+
+// archive formats detected by extractArchive
+const (
+	formatZip     = "zip"
+	formatTar     = "tar"
+	formatTarGzip = "tar.gz"
+	formatTarZstd = "tar.zst"
+)
+
+// addCodeArchive creates a project extracting a tar or zip archive
+// and make a push with that code to a gogs repository
+func addCodeArchive(rep Repository, gogs InitData, host string, l *log.Logger) error {
+	l.Printf("getting %v archive\n", rep.Archive.Source)
+	tmp, err := ioutil.TempDir("", rep.Name)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	file, err := fetchArchive(rep.Archive, tmp, l)
+	if err != nil {
+		return err
+	}
+
+	if rep.Archive.SHA256 != "" {
+		l.Println("verifying archive checksum")
+		err = verifyChecksum(file, rep.Archive.SHA256)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.Println("extracting archive")
+	dir, err := ioutil.TempDir("", rep.Name)
+	if err != nil {
+		return err
+	}
+	err = extractArchive(file, dir, rep.Archive.StripComponents, rep.Archive.Subdir, l)
+	if err != nil {
+		return err
+	}
+
+	// create repository
+	return createRepo(rep, gogs, host, dir, l)
+}
+
+// fetchArchive returns the local path of the archive,
+// http and https sources are downloaded into dir
+func fetchArchive(a Archive, dir string, l *log.Logger) (string, error) {
+	if a.Source == "" {
+		return "", errors.New("archive source is required")
+	}
+	if !strings.HasPrefix(a.Source, "http://") && !strings.HasPrefix(a.Source, "https://") {
+		return strings.TrimPrefix(a.Source, "file://"), nil
+	}
+
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = defaultDownloadTimeout
+	}
+	resp, err := cms.GetClient(timeout).Get(a.Source)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading %v code: %d message: %v", a.Source, resp.StatusCode, resp.Status)
+	}
+
+	file := filepath.Join(dir, "source")
+	output, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+
+	n, err := io.Copy(output, resp.Body)
+	if err != nil {
+		return "", err
+	}
+	l.Printf("%v bytes downloaded", n)
+	return file, output.Close()
+}
+
+// verifyChecksum checks the sha256 of a file against the expected hex value
+func verifyChecksum(file, expected string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(sum, expected) {
+		return fmt.Errorf("checksum mismatch for %v got: %v want: %v", file, sum, expected)
+	}
+	return nil
+}
+
+// archiveFormat detects the format of an archive using its first bytes
+func archiveFormat(r *bufio.Reader) (string, error) {
+	magic, err := r.Peek(262)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return formatZip, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return formatTarGzip, nil
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return formatTarZstd, nil
+	case len(magic) >= 262 && string(magic[257:262]) == "ustar":
+		return formatTar, nil
+	}
+	return "", errors.New("unknown archive format, supported formats are zip, tar, tar.gz and tar.zst")
+}
+
+// extractArchive extracts a zip, tar, tar.gz or tar.zst archive into dir,
+// the first strip directories of each file are removed and when subdir is
+// not empty only the files inside subdir are extracted
+func extractArchive(file, dir string, strip int, subdir string, l *log.Logger) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	format, err := archiveFormat(r)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	x := extractor{dir: dir, strip: strip, subdir: path.Clean("/" + filepath.ToSlash(subdir))[1:], log: l}
+	switch format {
+	case formatZip:
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		return x.zip(f, info.Size())
+	case formatTarGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return x.tar(gz)
+	case formatTarZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return x.tar(zr)
+	}
+	return x.tar(r)
+}
+
+// extractor writes the files of an archive inside dir
+type extractor struct {
+	dir    string
+	strip  int
+	subdir string
+	log    *log.Logger
+}
+
+// target returns the path to extract an archive entry,
+// false if the entry must be skipped
+func (x extractor) target(name string) (string, bool, error) {
+	name = filepath.ToSlash(name)
+	if path.IsAbs(name) {
+		return "", false, fmt.Errorf("illegal absolute file path %v in archive", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false, fmt.Errorf("illegal file path %v in archive", name)
+		}
+	}
+
+	parts := strings.Split(path.Clean(name), "/")
+	if len(parts) <= x.strip {
+		return "", false, nil
+	}
+	rel := strings.Join(parts[x.strip:], "/")
+	if x.subdir != "" {
+		if rel != x.subdir && !strings.HasPrefix(rel, x.subdir+"/") {
+			return "", false, nil
+		}
+		rel = strings.TrimPrefix(strings.TrimPrefix(rel, x.subdir), "/")
+	}
+	if rel == "" || rel == "." {
+		return "", false, nil
+	}
+	return filepath.Join(x.dir, filepath.FromSlash(rel)), true, nil
+}
+
+// symlink creates a symbolic link checking that it points inside dir
+func (x extractor) symlink(target, link string) error {
+	dest := link
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(target), link)
+	}
+	rel, err := filepath.Rel(x.dir, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("illegal link %v to %v in archive", target, link)
+	}
+	err = x.mkdir(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if err = x.contained(target); err != nil {
+		return err
+	}
+	return os.Symlink(link, target)
+}
+
+// file writes the content of r into target
+func (x extractor) file(target string, mode os.FileMode, r io.Reader) error {
+	err := x.mkdir(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if err = x.contained(target); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// mkdir creates the directory target checking that it is inside dir
+func (x extractor) mkdir(target string) error {
+	if err := x.contained(target); err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0755)
+}
+
+// contained returns an error when target is out of dir once the symlinks of the
+// existing part of its path are resolved or when target is an existing symlink,
+// the links of previous entries can't be used to write out of dir
+func (x extractor) contained(target string) error {
+	root, err := filepath.EvalSymlinks(x.dir)
+	if err != nil {
+		return err
+	}
+	existing := target
+	for {
+		info, err := os.Lstat(existing)
+		if err == nil {
+			if existing == target && info.Mode()&os.ModeSymlink != 0 {
+				return fmt.Errorf("illegal file path %v through a link in archive", target)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		existing = filepath.Dir(existing)
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("illegal file path %v through a link in archive", target)
+	}
+	return nil
+}
+
+// tar extracts a tar stream
+func (x extractor) tar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, ok, err := x.target(h.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = x.mkdir(target)
+		case tar.TypeReg:
+			err = x.file(target, h.FileInfo().Mode(), tr)
+		case tar.TypeSymlink:
+			err = x.symlink(target, h.Linkname)
+		default:
+			x.log.Printf("skipping %v, unsupported file type in archive", h.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// zip extracts a zip file
+func (x extractor) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		target, ok, err := x.target(f.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		mode := f.Mode()
+		if mode.IsDir() {
+			err = x.mkdir(target)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		if mode&os.ModeSymlink != 0 {
+			var link []byte
+			link, err = ioutil.ReadAll(rc)
+			if err == nil {
+				err = x.symlink(target, string(link))
+			}
+		} else {
+			err = x.file(target, mode, rc)
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// entry represents a file inside a test archive, a Link creates a symlink
+type entry struct {
+	Name string
+	Body string
+	Link string
+	Mode int64
+}
+
+var projectEntries = []entry{
+	{Name: "project-1.0/"},
+	{Name: "project-1.0/README.md", Body: "readme"},
+	{Name: "project-1.0/scripts/build.sh", Body: "#!/bin/sh", Mode: 0755},
+	{Name: "project-1.0/scripts/run.sh", Link: "build.sh"},
+	{Name: "project-1.0/app/main.go", Body: "package main"},
+}
+
+func tarArchive(t *testing.T, w io.Writer, entries []entry) {
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.Name, Mode: 0644, Size: int64(len(e.Body)), Typeflag: tar.TypeReg}
+		if e.Mode != 0 {
+			h.Mode = e.Mode
+		}
+		if e.Link != "" {
+			h.Typeflag = tar.TypeSymlink
+			h.Linkname = e.Link
+			h.Size = 0
+		} else if e.Name[len(e.Name)-1] == '/' {
+			h.Typeflag = tar.TypeDir
+			h.Mode = 0755
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e.Body))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func zipArchive(t *testing.T, w io.Writer, entries []entry) {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.Name, Method: zip.Deflate}
+		h.SetMode(0644)
+		if e.Mode != 0 {
+			h.SetMode(os.FileMode(e.Mode))
+		}
+		body := e.Body
+		if e.Link != "" {
+			h.SetMode(os.ModeSymlink | 0777)
+			body = e.Link
+		}
+		f, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeArchive creates an archive in the given format and returns its path
+func writeArchive(t *testing.T, dir, format string, entries []entry) string {
+	var buf bytes.Buffer
+	switch format {
+	case formatZip:
+		zipArchive(t, &buf, entries)
+	case formatTar:
+		tarArchive(t, &buf, entries)
+	case formatTarGzip:
+		gz := gzip.NewWriter(&buf)
+		tarArchive(t, gz, entries)
+		gz.Close()
+	case formatTarZstd:
+		zw, _ := zstd.NewWriter(&buf)
+		tarArchive(t, zw, entries)
+		zw.Close()
+	}
+	file := filepath.Join(dir, "archive."+format)
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestExtractArchive(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "archives")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, format := range []string{formatZip, formatTar, formatTarGzip, formatTarZstd} {
+		file := writeArchive(t, tmp, format, projectEntries)
+
+		// strip the top level directory
+		dir := filepath.Join(tmp, format, "strip")
+		err := extractArchive(file, dir, 1, "", testLogger)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", format, err)
+			continue
+		}
+		content, _ := ioutil.ReadFile(filepath.Join(dir, "README.md"))
+		if string(content) != "readme" {
+			t.Errorf("%v: README.md contains %s and want: readme", format, content)
+		}
+		info, err := os.Stat(filepath.Join(dir, "scripts", "build.sh"))
+		if err != nil || info.Mode().Perm()&0100 == 0 {
+			t.Errorf("%v: build.sh must be executable %v", format, err)
+		}
+		link, err := os.Readlink(filepath.Join(dir, "scripts", "run.sh"))
+		if err != nil || link != "build.sh" {
+			t.Errorf("%v: run.sh must link to build.sh got: %v %v", format, link, err)
+		}
+
+		// pick a subdirectory
+		dir = filepath.Join(tmp, format, "subdir")
+		err = extractArchive(file, dir, 0, "project-1.0/scripts/", testLogger)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", format, err)
+			continue
+		}
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != 2 {
+			t.Errorf("%v: subdir must contain 2 files got: %v", format, len(files))
+		}
+	}
+}
+
+func TestExtractArchiveTraversal(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "archives")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(tmp)
+
+	tests := map[string][]entry{
+		"parent":   {{Name: "project/../../evil.sh", Body: "evil"}},
+		"absolute": {{Name: "/tmp/evil.sh", Body: "evil"}},
+		"link":     {{Name: "project/passwd", Link: "../../etc/passwd"}},
+		"abs link": {{Name: "project/passwd", Link: "/etc/passwd"}},
+		"chained links": {
+			{Name: "l1", Link: "."},
+			{Name: "l2", Link: "l1/.."},
+			{Name: "l2/evil.txt", Body: "evil"},
+		},
+		"over a link": {
+			{Name: "project/link", Link: "README.md"},
+			{Name: "project/link", Body: "evil"},
+		},
+	}
+	for name, entries := range tests {
+		for _, format := range []string{formatZip, formatTarGzip} {
+			file := writeArchive(t, tmp, format, entries)
+			out := filepath.Join(tmp, "out", "extract")
+			err := extractArchive(file, out, 0, "", testLogger)
+			if err == nil {
+				t.Errorf("%v %v: must return an error", format, name)
+			}
+			if _, err = os.Stat(filepath.Join(tmp, "out", "evil.txt")); err == nil {
+				t.Errorf("%v %v: file written out of the dir", format, name)
+			}
+			os.RemoveAll(filepath.Join(tmp, "out"))
+		}
+	}
+
+	file := filepath.Join(tmp, "plain.txt")
+	ioutil.WriteFile(file, []byte("not an archive"), 0644)
+	if extractArchive(file, filepath.Join(tmp, "out"), 0, "", testLogger) == nil {
+		t.Error("must return an error with an unknown format")
+	}
+}
+
+func TestFetchArchive(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "archives")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(tmp)
+	file := writeArchive(t, tmp, formatZip, projectEntries)
+	data, _ := ioutil.ReadFile(file)
+	sum := sha256.Sum256(data)
+
+	// local files are not copied
+	local, err := fetchArchive(Archive{Source: "file://" + file}, tmp, testLogger)
+	if err != nil || local != file {
+		t.Errorf("local file must be used got: %v %v", local, err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/archive.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	downloaded, err := fetchArchive(Archive{Source: srv.URL + "/archive.zip"}, tmp, testLogger)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err = verifyChecksum(downloaded, hex.EncodeToString(sum[:])); err != nil {
+		t.Errorf("unexpected checksum error %v", err)
+	}
+	if verifyChecksum(downloaded, "0000") == nil {
+		t.Error("must return an error with a different checksum")
+	}
+
+	_, err = fetchArchive(Archive{Source: srv.URL + "/missing.zip"}, tmp, testLogger)
+	if err == nil {
+		t.Error("must return an error when the download fails")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultExecTimeout is the time in seconds to wait for an external executable
+const defaultExecTimeout = 600
+
+// addCodeExec generates a project running an external executable
+// and make a push with that code to a gogs repository
+func addCodeExec(rep Repository, gogs InitData, host string, l *log.Logger) error {
+	l.Printf("generating project with %v\n", rep.Exec.Command)
+	dir, err := ioutil.TempDir("", rep.Name)
+	if err != nil {
+		return err
+	}
+
+	out, err := runExec(rep, dir)
+	if err != nil {
+		return fmt.Errorf("error: %v output: %v", err.Error(), out)
+	}
+	l.Printf("%v output: %v \n", rep.Exec.Command, out)
+
+	// create repository
+	dir = filepath.Join(dir, rep.Exec.OutputDir)
+	empty, err := isEmptyDir(dir)
+	if err != nil {
+		return err
+	}
+	if empty {
+		return fmt.Errorf("%v did not generate any file in %v", rep.Exec.Command, dir)
+	}
+	return createRepo(rep, gogs, host, dir, l)
+}
+
+// runExec runs the executable configured in the repository using dir as the
+// target directory, it returns the combined stdout and stderr of the executable
+func runExec(rep Repository, dir string) (string, error) {
+	if rep.Exec.Command == "" {
+		return "", errors.New("exec command is required")
+	}
+
+	// the executable does not need the credentials of other content types
+	rep.GitImport.Username = ""
+	rep.GitImport.Password = ""
+	config, err := json.Marshal(rep)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := rep.Exec.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	args := append(append([]string{}, rep.Exec.Args...), dir)
+	cmd := exec.CommandContext(ctx, rep.Exec.Command, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), execEnv(rep.Exec.Env)...)
+	cmd.Stdin = bytes.NewReader(config)
+	// do not wait for children that keep the output open after a timeout
+	cmd.WaitDelay = 5 * time.Second
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return out.String(), fmt.Errorf("timeout reached after %ds running %v", timeout, rep.Exec.Command)
+	}
+	return out.String(), err
+}
+
+// execEnv returns the env map as a sorted slice of KEY=value
+func execEnv(env map[string]string) []string {
+	vars := []string{}
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// isEmptyDir checks if a directory does not contain any file
+func isEmptyDir(dir string) (bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	return len(files) == 0, nil
+}
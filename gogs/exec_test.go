@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScript creates an executable shell script with the given body
+func writeScript(t *testing.T, dir, body string) string {
+	path := filepath.Join(dir, "generator.sh")
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755)
+	if err != nil {
+		t.Fatalf("not possible to create script %v", err)
+	}
+	return path
+}
+
+func TestRunExec(t *testing.T) {
+	scripts, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(scripts)
+	target, err := ioutil.TempDir("", "target")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(target)
+
+	// the script copies the config from stdin into the last argument directory
+	script := writeScript(t, scripts, `for dir; do :; done
+cat > "$dir/config.json"
+echo "generated $1 $GREETING"
+`)
+	rep := Repository{
+		Name: "hello-world",
+		Exec: Exec{
+			Command: script,
+			Args:    []string{"--flag"},
+			Env:     map[string]string{"GREETING": "hello"},
+		},
+		GitImport: GitImport{Username: "ci", Password: "secret"},
+	}
+	out, err := runExec(rep, target)
+	if err != nil {
+		t.Fatalf("unexpected error %v output: %v", err, out)
+	}
+	if strings.TrimSpace(out) != "generated --flag hello" {
+		t.Errorf("unexpected output got: %v", out)
+	}
+
+	config, err := ioutil.ReadFile(filepath.Join(target, "config.json"))
+	if err != nil {
+		t.Fatalf("config file was not generated %v", err)
+	}
+	if !strings.Contains(string(config), `"name":"hello-world"`) {
+		t.Errorf("config file does not contain the repository got: %s", config)
+	}
+	if strings.Contains(string(config), "secret") || strings.Contains(string(config), `"username"`) {
+		t.Errorf("config file must not contain the git import credentials got: %s", config)
+	}
+	empty, err := isEmptyDir(target)
+	if err != nil || empty {
+		t.Errorf("target dir should not be empty got: %v %v", empty, err)
+	}
+}
+
+func TestRunExecErrors(t *testing.T) {
+	scripts, err := ioutil.TempDir("", "scripts")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(scripts)
+
+	// command is required
+	_, err = runExec(Repository{}, scripts)
+	if err == nil {
+		t.Error("must return an error without command")
+	}
+
+	// exit code different than 0
+	script := writeScript(t, scripts, "echo failing\nexit 3\n")
+	out, err := runExec(Repository{Exec: Exec{Command: script}}, scripts)
+	if err == nil {
+		t.Error("must return an error when the executable fails")
+	}
+	if strings.TrimSpace(out) != "failing" {
+		t.Errorf("output must be captured got: %v", out)
+	}
+
+	// timeout
+	script = writeScript(t, scripts, "exec sleep 10\n")
+	_, err = runExec(Repository{Exec: Exec{Command: script, Timeout: 1}}, scripts)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("must return a timeout error got: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// gogsUser represents the authenticated user in the gogs API
+type gogsUser struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// gogsRepository represents a repository in the gogs API
+type gogsRepository struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+	Empty       bool   `json:"empty"`
+	CloneURL    string `json:"clone_url"`
+	Owner       struct {
+		Username string `json:"username"`
+	} `json:"owner"`
+}
+
+// exportFile represents the FileConfig written by the export mode
+type exportFile struct {
+	InitData      InitData           `json:"init_data"`
+	Organizations []Organization     `json:"organizations"`
+	Repositories  []exportRepository `json:"repositories"`
+}
+
+// exportRepository represents a Repository of the FileConfig only with the fields
+// set by the export, the content blocks of the other content types are omitted
+type exportRepository struct {
+	Name             string     `json:"name"`
+	Description      string     `json:"description,omitempty"`
+	Private          bool       `json:"private,omitempty"`
+	Owner            string     `json:"owner"`
+	ContentSetupType string     `json:"content_setup_type"`
+	GitImport        *GitImport `json:"git_import,omitempty"`
+}
+
+// exportConfig returns the organizations of the user and the repositories owned by them
+// or by the user, the repositories are imported from the server with all their history
+func exportConfig(user, pass, host string) (exportFile, error) {
+	data := exportFile{Organizations: []Organization{}, Repositories: []exportRepository{}}
+	admin := gogsUser{}
+	err := gogsGet(user, pass, host+"/api/v1/user", &admin)
+	if err != nil {
+		return data, fmt.Errorf("error reading user %s", err.Error())
+	}
+	data.InitData, err = exportInitData(admin, host)
+	if err != nil {
+		return data, err
+	}
+	err = gogsGet(user, pass, host+"/api/v1/user/orgs", &data.Organizations)
+	if err != nil {
+		return data, fmt.Errorf("error reading organizations %s", err.Error())
+	}
+	repos := []gogsRepository{}
+	err = gogsGet(user, pass, host+"/api/v1/user/repos", &repos)
+	if err != nil {
+		return data, fmt.Errorf("error reading repositories %s", err.Error())
+	}
+
+	// the user also sees the repositories of other users where it is a collaborator,
+	// their owners are not in the export so they can't be created
+	owners := map[string]bool{user: true}
+	for _, o := range data.Organizations {
+		owners[o.Username] = true
+	}
+	for _, r := range repos {
+		if !owners[r.Owner.Username] {
+			log.Printf("skipping %v/%v repository, the owner is not exported", r.Owner.Username, r.Name)
+			continue
+		}
+		data.Repositories = append(data.Repositories, toExportRepository(r, user, host))
+	}
+	sort.Slice(data.Organizations, func(i, j int) bool {
+		return data.Organizations[i].Username < data.Organizations[j].Username
+	})
+	sort.Slice(data.Repositories, func(i, j int) bool {
+		a, b := data.Repositories[i], data.Repositories[j]
+		return a.Owner+"/"+a.Name < b.Owner+"/"+b.Name
+	})
+	return data, nil
+}
+
+// toExportRepository returns the configuration to import the repository from the server,
+// the password of the user is not exported, see GIT_IMPORT_PASSWORD
+func toExportRepository(r gogsRepository, user, host string) exportRepository {
+	rep := exportRepository{
+		Name:        r.Name,
+		Description: r.Description,
+		Private:     r.Private,
+		Owner:       r.Owner.Username,
+	}
+	if r.Empty {
+		rep.ContentSetupType = "empty"
+		log.Printf("%v/%v repository is empty", rep.Owner, rep.Name)
+		return rep
+	}
+	url := r.CloneURL
+	if url == "" {
+		url = fmt.Sprintf("%v/%v/%v.git", strings.TrimSuffix(host, "/"), rep.Owner, rep.Name)
+	}
+	rep.ContentSetupType = "git-import"
+	rep.GitImport = &GitImport{URL: url, KeepHistory: true, Username: user}
+	return rep
+}
+
+// exportInitData returns the init data of the server without the passwords and the paths,
+// they are not available in the API and must be filled before applying the export
+func exportInitData(admin gogsUser, host string) (InitData, error) {
+	u, err := url.Parse(host)
+	if err != nil || u.Hostname() == "" {
+		return InitData{}, fmt.Errorf("invalid host %v", host)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return InitData{
+		Domain:     u.Hostname(),
+		HTTPPort:   port,
+		APPUrl:     strings.TrimSuffix(host, "/"),
+		AdminName:  admin.Username,
+		AdminEmail: admin.Email,
+	}, nil
+}
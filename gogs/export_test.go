@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func TestExportConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "tikal" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/user":
+			w.Write([]byte(`{"id":1,"username":"tikal","full_name":"","email":"tikal@example.org"}`))
+		case "/api/v1/user/orgs":
+			w.Write([]byte(`[{"id":2,"username":"orgB","full_name":"Org B"},{"id":1,"username":"orgA","website":"https://a.example.org"}]`))
+		case "/api/v1/user/repos":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"name": "web", "private": true, "clone_url": "https://git.example.org/orgA/web.git",
+					"owner": map[string]string{"username": "orgA"}},
+				{"name": "notes", "description": "empty", "empty": true, "owner": map[string]string{"username": "tikal"}},
+				{"name": "api", "owner": map[string]string{"username": "orgA"}},
+				{"name": "shared", "owner": map[string]string{"username": "jdoe"}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	data, err := exportConfig("tikal", "secret", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	host, _ := url.Parse(srv.URL)
+	initData := InitData{Domain: host.Hostname(), HTTPPort: host.Port(), APPUrl: srv.URL, AdminName: "tikal", AdminEmail: "tikal@example.org"}
+	if data.InitData != initData {
+		t.Errorf("init data got: %+v want: %+v", data.InitData, initData)
+	}
+	orgs := []Organization{{Username: "orgA", WebSite: "https://a.example.org"}, {Username: "orgB", FullName: "Org B"}}
+	if !reflect.DeepEqual(data.Organizations, orgs) {
+		t.Errorf("organizations got: %v want: %v", data.Organizations, orgs)
+	}
+	repos := []exportRepository{
+		{Name: "api", Owner: "orgA", ContentSetupType: "git-import",
+			GitImport: &GitImport{URL: srv.URL + "/orgA/api.git", KeepHistory: true, Username: "tikal"}},
+		{Name: "web", Owner: "orgA", Private: true, ContentSetupType: "git-import",
+			GitImport: &GitImport{URL: "https://git.example.org/orgA/web.git", KeepHistory: true, Username: "tikal"}},
+		{Name: "notes", Owner: "tikal", Description: "empty", ContentSetupType: "empty"},
+	}
+	if !reflect.DeepEqual(data.Repositories, repos) {
+		t.Errorf("repositories got: %+v want: %+v", data.Repositories, repos)
+	}
+
+	// the export only contains the git import and can be applied as a FileConfig
+	content, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, block := range []string{"danta_aem_archetype", "exec", "render", "author"} {
+		if strings.Contains(string(content), `"`+block+`"`) {
+			t.Errorf("export contains the empty %v block %s", block, content)
+		}
+	}
+	config := FileConfig{}
+	if err = json.Unmarshal(content, &config); err != nil || config.Repositories[0].GitImport.URL != repos[0].GitImport.URL {
+		t.Errorf("export is not a FileConfig %v %v", config.Repositories, err)
+	}
+
+	if _, err = exportConfig("tikal", "wrong", srv.URL); err == nil {
+		t.Error("must return an error with invalid credentials")
+	}
+}
+
+func TestExportInitData(t *testing.T) {
+	admin := gogsUser{Username: "tikal", Email: "tikal@example.org"}
+	tests := map[string]InitData{
+		"http://gogs:3000":         {Domain: "gogs", HTTPPort: "3000", APPUrl: "http://gogs:3000"},
+		"https://git.example.org/": {Domain: "git.example.org", HTTPPort: "443", APPUrl: "https://git.example.org"},
+		"http://git.example.org":   {Domain: "git.example.org", HTTPPort: "80", APPUrl: "http://git.example.org"},
+	}
+	for host, want := range tests {
+		want.AdminName, want.AdminEmail = admin.Username, admin.Email
+		got, err := exportInitData(admin, host)
+		if err != nil || got != want {
+			t.Errorf("%v got: %+v %v want: %+v", host, got, err, want)
+		}
+	}
+	if _, err := exportInitData(admin, "gogs"); err == nil {
+		t.Error("must return an error without the hostname")
+	}
+}
+
+func TestImportAuth(t *testing.T) {
+	auth, err := importAuth(GitImport{URL: "https://git.example.org/repo.git"})
+	if err != nil || auth != nil {
+		t.Errorf("http url without username got: %v %v", auth, err)
+	}
+
+	auth, err = importAuth(GitImport{URL: "https://git.example.org/repo.git", Username: "tikal", Password: "secret"})
+	if err != nil || !reflect.DeepEqual(auth, &githttp.BasicAuth{Username: "tikal", Password: "secret"}) {
+		t.Errorf("basic auth got: %v %v", auth, err)
+	}
+
+	os.Setenv(gitImportPasswordEnv, "from-env")
+	defer os.Unsetenv(gitImportPasswordEnv)
+	auth, err = importAuth(GitImport{URL: "https://git.example.org/repo.git", Username: "tikal"})
+	if err != nil || !reflect.DeepEqual(auth, &githttp.BasicAuth{Username: "tikal", Password: "from-env"}) {
+		t.Errorf("basic auth from %v got: %v %v", gitImportPasswordEnv, auth, err)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	cryptossh "golang.org/x/crypto/ssh"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+const (
+	// gitSSHKeyEnv is the private key used to clone ssh urls
+	gitSSHKeyEnv = "GIT_SSH_KEY"
+	// gitImportPasswordEnv is the password of the git imports with a username and without password
+	gitImportPasswordEnv = "GIT_IMPORT_PASSWORD"
+	// sourceRemote is the remote name used to import code from other repositories
+	sourceRemote = "source"
+)
+
+// git operations reported in GitError
+const (
+	gitOpInit     = "init"
+	gitOpFetch    = "fetch"
+	gitOpCheckout = "checkout"
+	gitOpCommit   = "commit"
+	gitOpRemote   = "remote"
+	gitOpPush     = "push"
+)
+
+// hashRegexp matches full or abbreviated commit hashes
+var hashRegexp = regexp.MustCompile("^[0-9a-f]{7,40}$")
+
+// GitError represents an error in a git operation over a repository directory
+type GitError struct {
+	Op  string
+	Dir string
+	Err error
+}
+
+func (e *GitError) Error() string {
+	return fmt.Sprintf("git %v in %v: %v", e.Op, e.Dir, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// gitAuthor returns the author of the repository commits,
+// the admin user is used when the repository does not have an author
+func gitAuthor(rep Repository, gogs InitData) GitAuthor {
+	author := rep.Author
+	if author.Name == "" {
+		author.Name = gogs.AdminName
+	}
+	if author.Email == "" {
+		author.Email = gogs.AdminEmail
+	}
+	return author
+}
+
+// gogsAuth returns the admin credentials to push to gogs
+func gogsAuth(gogs InitData) transport.AuthMethod {
+	return &githttp.BasicAuth{Username: gogs.AdminName, Password: gogs.AdminPasswd}
+}
+
+// sourceAuth returns the credentials to read a source repository,
+// ssh urls use the private key in GIT_SSH_KEY or ~/.ssh/id_rsa
+func sourceAuth(url string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	if ep.Protocol != "ssh" {
+		return nil, nil
+	}
+
+	key := cms.GetEnv(gitSSHKeyEnv, filepath.Join(os.Getenv("HOME"), ".ssh", "id_rsa"))
+	user := ep.User
+	if user == "" {
+		user = "git"
+	}
+	auth, err := gitssh.NewPublicKeysFromFile(user, key, "")
+	if err != nil {
+		return nil, err
+	}
+	// same behavior as StrictHostKeyChecking=no, the sources are configured by the admin
+	auth.HostKeyCallback = cryptossh.InsecureIgnoreHostKey()
+	return auth, nil
+}
+
+// initRepo initializes a new repository
+func initRepo(dir string) error {
+	_, err := git.PlainInit(dir, false)
+	if err != nil {
+		return &GitError{Op: gitOpInit, Dir: dir, Err: err}
+	}
+	return nil
+}
+
+// initBare initializes a new bare repository
+func initBare(dir string) error {
+	_, err := git.PlainInit(dir, true)
+	if err != nil {
+		return &GitError{Op: gitOpInit, Dir: dir, Err: err}
+	}
+	return nil
+}
+
+// openRepo opens an existing repository reporting errors as the given operation
+func openRepo(dir, op string) (*git.Repository, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, &GitError{Op: op, Dir: dir, Err: err}
+	}
+	return r, nil
+}
+
+// resolveRef returns the remote reference name of a branch, tag or HEAD,
+// an empty name is returned when ref is not a remote reference
+func resolveRef(url, ref string, auth transport.AuthMethod) (plumbing.ReferenceName, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: sourceRemote, URLs: []string{url}})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", err
+	}
+
+	candidates := []plumbing.ReferenceName{
+		plumbing.ReferenceName(ref),
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+	}
+	for _, name := range candidates {
+		for _, r := range refs {
+			if r.Name() != name {
+				continue
+			}
+			if r.Type() == plumbing.SymbolicReference {
+				return r.Target(), nil
+			}
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// fetch fetches a branch, tag or commit from a repository url and returns
+// the fetched commit, depth greater than 0 creates a shallow fetch of
+// branches and tags, commits are fetched with the full history
+func fetch(dir, url, ref string, depth int, auth transport.AuthMethod) (plumbing.Hash, error) {
+	r, err := openRepo(dir, gitOpFetch)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	gitErr := func(err error) error {
+		return &GitError{Op: gitOpFetch, Dir: dir, Err: err}
+	}
+
+	name, err := resolveRef(url, ref, auth)
+	if err != nil {
+		return plumbing.ZeroHash, gitErr(err)
+	}
+	local := plumbing.ReferenceName("refs/" + sourceRemote + "/import")
+	specs := []config.RefSpec{config.RefSpec("+" + name.String() + ":" + local.String())}
+	if name == "" {
+		if !hashRegexp.MatchString(strings.ToLower(ref)) {
+			return plumbing.ZeroHash, gitErr(fmt.Errorf("%v is not a branch, tag or commit of %v", ref, url))
+		}
+		depth = 0
+		specs = []config.RefSpec{"+refs/heads/*:refs/" + sourceRemote + "/heads/*", "+refs/tags/*:refs/tags/*"}
+	}
+
+	remote, err := r.CreateRemoteAnonymous(&config.RemoteConfig{Name: "anonymous", URLs: []string{url}})
+	if err != nil {
+		return plumbing.ZeroHash, gitErr(err)
+	}
+	err = remote.Fetch(&git.FetchOptions{RemoteName: "anonymous", RefSpecs: specs, Depth: depth, Auth: auth, Tags: git.NoTags})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return plumbing.ZeroHash, gitErr(err)
+	}
+
+	rev := plumbing.Revision(local)
+	if name == "" {
+		rev = plumbing.Revision(ref)
+	}
+	hash, err := r.ResolveRevision(rev)
+	if err != nil {
+		return plumbing.ZeroHash, gitErr(err)
+	}
+	// annotated tags point to a tag object
+	commit, err := object.GetCommit(r.Storer, *hash)
+	if err != nil {
+		tag, tagErr := r.TagObject(*hash)
+		if tagErr != nil {
+			return plumbing.ZeroHash, gitErr(err)
+		}
+		commit, err = tag.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, gitErr(err)
+		}
+	}
+	return commit.Hash, nil
+}
+
+// checkout updates the files in the working tree to match a given commit
+func checkout(dir string, hash plumbing.Hash) error {
+	r, err := openRepo(dir, gitOpCheckout)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return &GitError{Op: gitOpCheckout, Dir: dir, Err: err}
+	}
+	err = w.Checkout(&git.CheckoutOptions{Hash: hash, Force: true})
+	if err != nil {
+		return &GitError{Op: gitOpCheckout, Dir: dir, Err: err}
+	}
+	return nil
+}
+
+// fetchAll fetches all the branches and tags of a repository url
+// into the local branches and tags of a bare repository
+func fetchAll(dir, url string, auth transport.AuthMethod) error {
+	r, err := openRepo(dir, gitOpFetch)
+	if err != nil {
+		return err
+	}
+	remote, err := r.CreateRemote(&config.RemoteConfig{Name: sourceRemote, URLs: []string{url}})
+	if err != nil {
+		return &GitError{Op: gitOpRemote, Dir: dir, Err: err}
+	}
+	specs := []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}
+	err = remote.Fetch(&git.FetchOptions{RemoteName: sourceRemote, RefSpecs: specs, Auth: auth})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return &GitError{Op: gitOpFetch, Dir: dir, Err: err}
+	}
+	return nil
+}
+
+// commitAll creates a commit adding all the changed files in a repository
+func commitAll(dir, msg string, author GitAuthor) error {
+	r, err := openRepo(dir, gitOpCommit)
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return &GitError{Op: gitOpCommit, Dir: dir, Err: err}
+	}
+
+	// adds all files to staging area
+	err = w.AddWithOptions(&git.AddOptions{All: true})
+	if err != nil {
+		return &GitError{Op: gitOpCommit, Dir: dir, Err: err}
+	}
+
+	// commit files
+	sign := &object.Signature{Name: author.Name, Email: author.Email, When: time.Now()}
+	_, err = w.Commit(msg, &git.CommitOptions{Author: sign, Committer: sign})
+	if err != nil {
+		return &GitError{Op: gitOpCommit, Dir: dir, Err: err}
+	}
+	return nil
+}
+
+// addRemote adds a new remote url in a repository
+func addRemote(url, dir, remote string) error {
+	r, err := openRepo(dir, gitOpRemote)
+	if err != nil {
+		return err
+	}
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: remote, URLs: []string{url}})
+	if err != nil {
+		return &GitError{Op: gitOpRemote, Dir: dir, Err: err}
+	}
+	return nil
+}
+
+// push makes a push of the current branch to a repository url
+func push(dir, url string, auth transport.AuthMethod) error {
+	r, err := openRepo(dir, gitOpPush)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return &GitError{Op: gitOpPush, Dir: dir, Err: err}
+	}
+	spec := config.RefSpec(head.Name().String() + ":" + head.Name().String())
+	return pushRefs(r, dir, url, auth, spec)
+}
+
+// pushAll makes a push of all the branches and tags in a repository
+func pushAll(dir, url string, auth transport.AuthMethod) error {
+	r, err := openRepo(dir, gitOpPush)
+	if err != nil {
+		return err
+	}
+	return pushRefs(r, dir, url, auth, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+}
+
+// pushRefs pushes the refspecs to a repository url
+func pushRefs(r *git.Repository, dir, url string, auth transport.AuthMethod, specs ...config.RefSpec) error {
+	remote, err := r.CreateRemoteAnonymous(&config.RemoteConfig{Name: "anonymous", URLs: []string{url}})
+	if err != nil {
+		return &GitError{Op: gitOpPush, Dir: dir, Err: err}
+	}
+	err = remote.Push(&git.PushOptions{RemoteName: "anonymous", RefSpecs: specs, Auth: auth})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return &GitError{Op: gitOpPush, Dir: dir, Err: err}
+	}
+	return nil
+}
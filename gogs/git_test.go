@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitAndPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(dir)
+	target, err := ioutil.TempDir("", "target")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(target)
+	gitRun(t, target, "init", "-q", "--bare")
+
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+	err = initRepo(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	rep := Repository{Author: GitAuthor{Name: "Developer"}}
+	err = commitAll(dir, "Initial code", gitAuthor(rep, InitData{AdminName: "tikal", AdminEmail: "admin@xumak.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = addRemote("http://gogs:3000/myOrg/hello-world", dir, "origin")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = push(dir, "file://"+target, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	author := gitRun(t, target, "log", "-1", "--format=%an <%ae> %s")
+	if author != "Developer <admin@xumak.com> Initial code" {
+		t.Errorf("unexpected commit got: %v", author)
+	}
+	files := gitRun(t, target, "ls-tree", "-r", "--name-only", "HEAD")
+	if files != "src/main.go" {
+		t.Errorf("unexpected files got: %v", files)
+	}
+}
+
+func TestGitError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repo")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(dir)
+
+	err = commitAll(dir, "Initial code", GitAuthor{})
+	var gitErr *GitError
+	if !errors.As(err, &gitErr) || gitErr.Op != gitOpCommit {
+		t.Errorf("must return a commit GitError got: %v", err)
+	}
+
+	initRepo(dir)
+	ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("file"), 0644)
+	commitAll(dir, "Initial code", GitAuthor{Name: "tikal", Email: "admin@xumak.com"})
+	err = push(dir, "file://"+filepath.Join(dir, "missing"), nil)
+	if !errors.As(err, &gitErr) || gitErr.Op != gitOpPush {
+		t.Errorf("must return a push GitError got: %v", err)
+	}
+
+	_, err = fetch(dir, "file://"+dir, "missing-branch", 1, nil)
+	if !errors.As(err, &gitErr) || gitErr.Op != gitOpFetch {
+		t.Errorf("must return a fetch GitError got: %v", err)
+	}
+}
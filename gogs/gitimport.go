@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// dantaDemoURL is the repository imported by the danta-aem-demo content type
+const dantaDemoURL = "git@github.com:xumak-grid/demo.git"
+
+// addCodeGitImport gets the code from an existing git repository
+// and make a push with that code to a gogs repository
+func addCodeGitImport(rep Repository, gogs InitData, host string, l *log.Logger) error {
+	src := rep.GitImport
+	l.Printf("importing code from %v repository\n", src.URL)
+	dir, err := ioutil.TempDir("", rep.Name)
+	if err != nil {
+		return err
+	}
+
+	if !src.KeepHistory {
+		err = importTree(src, dir, l)
+		if err != nil {
+			return err
+		}
+		return createRepo(rep, gogs, host, dir, l)
+	}
+
+	if len(rep.Render.Templates) > 0 || len(rep.Render.Replacements) > 0 {
+		l.Println("render is not applied when keep_history is set")
+	}
+	l.Println("fetching all the branches and tags")
+	err = importHistory(src, dir)
+	if err != nil {
+		return err
+	}
+
+	l.Printf("push history to %v/%v/%v repository\n", host, rep.Owner, rep.Name)
+	url, err := pushURL(rep, gogs)
+	if err != nil {
+		return err
+	}
+	return pushAll(dir, url, gogsAuth(gogs))
+}
+
+// importHistory fetches all the branches and tags of the source repository into a bare repository in dir
+func importHistory(src GitImport, dir string) error {
+	if src.URL == "" {
+		return errors.New("git import url is required")
+	}
+	auth, err := importAuth(src)
+	if err != nil {
+		return err
+	}
+
+	err = initBare(dir)
+	if err != nil {
+		return err
+	}
+	return fetchAll(dir, src.URL, auth)
+}
+
+// importAuth returns the credentials to read the source repository,
+// the username and password are used for http(s) urls
+func importAuth(src GitImport) (transport.AuthMethod, error) {
+	if src.Username == "" {
+		return sourceAuth(src.URL)
+	}
+	pass := src.Password
+	if pass == "" {
+		pass = os.Getenv(gitImportPasswordEnv)
+	}
+	return &githttp.BasicAuth{Username: src.Username, Password: pass}, nil
+}
+
+// importTree gets the files of the configured ref into dir without the git history
+func importTree(src GitImport, dir string, l *log.Logger) error {
+	if src.URL == "" {
+		return errors.New("git import url is required")
+	}
+	ref := src.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	auth, err := importAuth(src)
+	if err != nil {
+		return err
+	}
+
+	err = initRepo(dir)
+	if err != nil {
+		return err
+	}
+
+	l.Printf("fetching %v from %v repository\n", ref, src.URL)
+	hash, err := fetch(dir, src.URL, ref, src.Depth, auth)
+	if err != nil {
+		return err
+	}
+	err = checkout(dir, hash)
+	if err != nil {
+		return err
+	}
+
+	l.Println("removing .git directory in source repository")
+	return os.RemoveAll(filepath.Join(dir, ".git"))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRun runs a git command in dir with a test identity
+func gitRun(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@xumak.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// sourceRepo creates a repository with two commits on master, a tag v1 on
+// the first commit and a feature branch, it returns the dir and first commit
+func sourceRepo(t *testing.T) (string, string) {
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	gitRun(t, dir, "init", "-q")
+	gitRun(t, dir, "checkout", "-q", "-b", "master")
+	ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("one"), 0644)
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-q", "-m", "one")
+	first := gitRun(t, dir, "rev-parse", "HEAD")
+	gitRun(t, dir, "tag", "-a", "-m", "version 1", "v1")
+	ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("two"), 0644)
+	gitRun(t, dir, "commit", "-q", "-am", "two")
+	gitRun(t, dir, "checkout", "-q", "-b", "feature")
+	ioutil.WriteFile(filepath.Join(dir, "feature.txt"), []byte("feature"), 0644)
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-q", "-m", "feature")
+	gitRun(t, dir, "checkout", "-q", "master")
+	return dir, first
+}
+
+func TestImportTree(t *testing.T) {
+	src, first := sourceRepo(t)
+	defer os.RemoveAll(src)
+
+	tests := []struct {
+		ref     string
+		depth   int
+		content string
+		feature bool
+	}{
+		{ref: "", content: "two"},
+		{ref: "master", depth: 1, content: "two"},
+		{ref: "v1", content: "one"},
+		{ref: first, depth: 1, content: "one"},
+		{ref: "feature", content: "two", feature: true},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "import")
+		if err != nil {
+			t.Fatal("not possible to create dir")
+		}
+		defer os.RemoveAll(dir)
+
+		err = importTree(GitImport{URL: "file://" + src, Ref: tt.ref, Depth: tt.depth}, dir, testLogger)
+		if err != nil {
+			t.Errorf("ref %v: unexpected error %v", tt.ref, err)
+			continue
+		}
+		content, _ := ioutil.ReadFile(filepath.Join(dir, "file.txt"))
+		if string(content) != tt.content {
+			t.Errorf("ref %v: file.txt contains %s and want: %v", tt.ref, content, tt.content)
+		}
+		_, err = os.Stat(filepath.Join(dir, "feature.txt"))
+		if (err == nil) != tt.feature {
+			t.Errorf("ref %v: feature.txt exists %v and want: %v", tt.ref, err == nil, tt.feature)
+		}
+		_, err = os.Stat(filepath.Join(dir, ".git"))
+		if !os.IsNotExist(err) {
+			t.Errorf("ref %v: .git directory must be removed", tt.ref)
+		}
+	}
+
+	if importTree(GitImport{}, src, testLogger) == nil {
+		t.Error("must return an error without url")
+	}
+}
+
+func TestImportHistory(t *testing.T) {
+	src, first := sourceRepo(t)
+	defer os.RemoveAll(src)
+	dir, err := ioutil.TempDir("", "bare")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(dir)
+	target, err := ioutil.TempDir("", "target")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(target)
+	gitRun(t, target, "init", "-q", "--bare")
+
+	err = importHistory(GitImport{URL: "file://" + src}, dir)
+	if err != nil {
+		t.Fatalf("unexpected error fetching %v", err)
+	}
+	err = pushAll(dir, "file://"+target, nil)
+	if err != nil {
+		t.Fatalf("unexpected error pushing %v", err)
+	}
+
+	refs := gitRun(t, target, "show-ref")
+	for _, ref := range []string{"refs/heads/master", "refs/heads/feature", "refs/tags/v1"} {
+		if !strings.Contains(refs, ref) {
+			t.Errorf("%v was not pushed got: %v", ref, refs)
+		}
+	}
+	if gitRun(t, target, "rev-list", "--max-parents=0", "master") != first {
+		t.Error("history was not preserved")
+	}
+}
@@ -4,14 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	cms "github.com/xumak-grid/init-containers/pkg/commons"
@@ -21,6 +20,15 @@ import (
 const (
 	gogsConfigFileEnv = "GOGS_CONFIG_FILE"
 	gogsHostEnv       = "GOGS_HOST"
+	// gogsConcurrencyEnv is the number of organizations and repositories created at the same time
+	gogsConcurrencyEnv = "GOGS_CONCURRENCY"
+	// gogsModeEnv is apply (default) to configure gogs or export to write its organizations and repositories
+	gogsModeEnv = "GOGS_MODE"
+	// gogsExportFileEnv is the file written by the export mode, yaml with the .yaml or .yml extension
+	gogsExportFileEnv = "GOGS_EXPORT_FILE"
+	// gogsUserEnv and gogsPassEnv are the credentials of the export mode
+	gogsUserEnv = "GOGS_USER"
+	gogsPassEnv = "GOGS_PASS"
 )
 
 // serviceReady checks if a service is ready
@@ -114,293 +122,196 @@ func gogsPost(user, pass, host string, obj interface{}) error {
 	return nil
 }
 
-// addCode supports to add code to a repository
-func addCode(rep Repository, data InitData, host string) error {
-	log.Println("configuring git with username and email")
-	err := configGit(data.AdminName, data.AdminEmail)
-	CheckIfError(err)
+// gogsGet makes a GET request to gogs and decodes the response into out
+func gogsGet(user, pass, url string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(user, pass)
+	client := cms.GetClient(5)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error reading resource code: %d message: %v", resp.StatusCode, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// addCode supports to add code to a repository
+func addCode(rep Repository, data InitData, host string, l *log.Logger) error {
 	switch rep.ContentSetupType {
 	// add code from danta aem demo repository
 	case "danta-aem-demo":
-		demoURL := "git@github.com:xumak-grid/demo.git"
-		addCodeFromRepo(rep, data, demoURL, host)
+		rep.GitImport = GitImport{URL: dantaDemoURL}
+		return addCodeGitImport(rep, data, host, l)
+	// add code from an existing git repository
+	case "git-import":
+		return addCodeGitImport(rep, data, host, l)
 	// add code from project generated using danta AEM archetype
 	case "danta-aem-archetype":
-		addCodeFromDantaAEM(rep, data, host)
+		rep.MavenArchetype = dantaArchetype(rep.DantaAEM)
+		return addCodeMavenArchetype(rep, data, host, l)
 	// add code for EP commerce project
 	case "ep-commerce":
-		addCodeEP(rep, data, host)
+		return addCodeEP(rep, data, host, l)
+	// add code from project generated using bloomreach archetype
 	case "bloomreach-archetype":
-		addCodeBR(rep, data, host)
-	default:
-		return fmt.Errorf("error adding code: the %v is not a valid content type for a repository", rep.ContentSetupType)
+		rep.MavenArchetype = bloomreachArchetype(rep.BR)
+		return addCodeMavenArchetype(rep, data, host, l)
+	// add code from project generated using any maven archetype
+	case "maven-archetype":
+		return addCodeMavenArchetype(rep, data, host, l)
+	// add code from a zip or tar archive
+	case "archive":
+		return addCodeArchive(rep, data, host, l)
+	// add code generated by an external executable
+	case "exec":
+		return addCodeExec(rep, data, host, l)
 	}
-	return nil
-}
-
-// addCodeFromRepo gets initial code from a git reposotory,
-// and make a push with that code to a new gogs repository
-func addCodeFromRepo(rep Repository, gogs InitData, src, host string) {
-	log.Println("adding code from existing repository")
-	dir, err := ioutil.TempDir("", rep.Owner)
-	CheckIfError(err)
-
-	log.Printf("cloning %v repository\n", src)
-	err = clone(src, dir, ".")
-	CheckIfError(err)
-
-	log.Println("removing .git directory in source repository")
-	err = os.RemoveAll(filepath.Join(dir, ".git"))
-	CheckIfError(err)
-
-	// create repo
-	createRepo(rep, gogs, host, dir)
-}
-
-// addCodeFromDantaAEM generates a project using the Danta AEM archetype
-// and make a push with that code to a gogs repository
-func addCodeFromDantaAEM(rep Repository, gogs InitData, host string) {
-	log.Println("generating danta aem project")
-	dir, err := ioutil.TempDir("", rep.DantaAEM.AppName)
-	CheckIfError(err)
-
-	cmd := newCMD("mvn",
-		"archetype:generate",
-		"-DarchetypeGroupId=io.tikaltechnologies.danta",
-		"-DarchetypeArtifactId=danta-aem-archetype",
-		"-DarchetypeVersion="+rep.DantaAEM.ArchetypeVersion,
-		"-DgroupId="+rep.DantaAEM.GroupID,
-		"-DartifactId="+rep.DantaAEM.ArtifactID,
-		"-Dproject-app-name="+rep.DantaAEM.AppName,
-		"-Dpackage="+rep.DantaAEM.Package,
-		"-Dcq-server="+rep.DantaAEM.AEMServer,
-		"-Dnexus-public-url="+rep.DantaAEM.NexusURL,
-		"-DinteractiveMode=false")
-	cmd.Dir = dir
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err = cmd.Run()
-	if err != nil {
-		log.Fatalf("error: %v ouput: %v \n", err.Error(), out.String())
-	}
-	// create repository
-	dir = filepath.Join(dir, rep.DantaAEM.AppName)
-	createRepo(rep, gogs, host, dir)
+	return fmt.Errorf("error adding code: the %v is not a valid content type for a repository", rep.ContentSetupType)
 }
 
 // addCodeEP creates a project using the EP code sources
 // and make a push with that code to a gogs repository
-func addCodeEP(rep Repository, gogs InitData, host string) {
-	log.Println("downloading EP commerce project")
+func addCodeEP(rep Repository, gogs InitData, host string, l *log.Logger) error {
+	l.Println("downloading EP commerce project")
 	url := rep.EP.SourceCodeURL
 	dir, err := ioutil.TempDir("", rep.Name)
-	CheckIfError(err)
-
-	// create file path
-	file := filepath.Join(dir, "source")
-	output, err := os.Create(file)
-	CheckIfError(err)
+	if err != nil {
+		return err
+	}
 
 	// download file
-	response, err := http.Get(url)
-	CheckIfError(err)
-	defer response.Body.Close()
-
-	n, err := io.Copy(output, response.Body)
-	CheckIfError(err)
-	log.Printf("%v bytes downloaded", n)
+	file, err := fetchArchive(Archive{Source: url}, dir, l)
+	if err != nil {
+		return err
+	}
 
-	log.Println("unzipping EP commerce source code")
-	cmd := newCMD("unzip", file)
-	cmd.Dir = dir
-	err = cmd.Run()
-	CheckIfError(err)
+	l.Println("unzipping EP commerce source code")
+	err = extractArchive(file, dir, 0, "", l)
+	if err != nil {
+		return err
+	}
 
-	log.Println("editing settings.xml file")
+	l.Println("editing settings.xml file")
 	path := filepath.Join(dir, "ep-commerce", "extensions", "maven", "settings.xml")
 	err = cms.ReplaceStr(path, "PROJECT_REPOSITORY_GROUP_URL", rep.EP.MavenRepURL)
 	if err != nil {
-		log.Printf("error editing settings file: %s \n", err.Error())
+		l.Printf("error editing settings file: %s \n", err.Error())
 	}
 
-	log.Println("changing versions")
+	l.Println("changing versions")
 	dir = filepath.Join(dir, "ep-commerce")
-	cmd = newCMD("./devops/scripts/set-ep-versions.sh", "-s", path, rep.EP.PlatformVersion, rep.EP.ExtensionVersion)
+	cmd := newCMD(l, "./devops/scripts/set-ep-versions.sh", "-s", path, rep.EP.PlatformVersion, rep.EP.ExtensionVersion)
 	cmd.Dir = dir
 	err = cmd.Run()
 	if err != nil {
-		log.Printf("error setting versions: %s \n", err.Error())
+		l.Printf("error setting versions: %s \n", err.Error())
 	}
 
-	log.Println("removing unused files")
-	cmd = newCMD("rm", "commerce-manager/cm-modules/pom.xml.versionsBackup")
+	l.Println("removing unused files")
+	cmd = newCMD(l, "rm", "commerce-manager/cm-modules/pom.xml.versionsBackup")
 	cmd.Dir = dir
 	err = cmd.Run()
 	if err != nil {
-		log.Printf("error removing unused files: %v \n", err.Error())
+		l.Printf("error removing unused files: %v \n", err.Error())
 	}
 
 	// create repository
-	createRepo(rep, gogs, host, dir)
+	return createRepo(rep, gogs, host, dir, l)
 }
 
-func addCodeBR(rep Repository, gogs InitData, host string) {
-	log.Println("generating bloomreach project")
-	dir, err := ioutil.TempDir("", rep.BR.ProjectName)
-	CheckIfError(err)
-
-	cmd := newCMD("mvn",
-		"org.apache.maven.plugins:maven-archetype-plugin:2.4:generate",
-		"-DarchetypeRepository=https://maven.onehippo.com/maven2",
-		"-DarchetypeGroupId=org.onehippo.cms7",
-		"-DarchetypeArtifactId=hippo-project-archetype",
-		"-DarchetypeVersion=12.2.0",
-		"-DgroupId="+rep.BR.GroupID,
-		"-DartifactId="+rep.BR.ArtifactID,
-		"-Dversion="+rep.BR.Version,
-		"-Dpackage="+rep.BR.Package,
-		"-DprojectName="+rep.BR.ProjectName,
-		"-DinteractiveMode=false")
-	cmd.Dir = dir
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err = cmd.Run()
+// createRepo commits the files in dir and make a push to a gogs repository
+func createRepo(rep Repository, gogs InitData, host, dir string, l *log.Logger) error {
+	l.Println("rendering repository content")
+	err := renderContent(rep, gogs, dir, l)
 	if err != nil {
-		log.Fatalf("error: %v ouput: %v \n", err.Error(), out.String())
+		return err
 	}
-	// create repository
-	dir = filepath.Join(dir, rep.BR.ArtifactID)
-	createRepo(rep, gogs, host, dir)
-}
 
-func createRepo(rep Repository, gogs InitData, host, dir string) {
-	log.Println("initializing new repository")
-	err := initRepo(dir)
-	CheckIfError(err)
-
-	log.Println("commit files")
-	err = commitAll(dir, "Initial code")
-	CheckIfError(err)
-
-	log.Println("adding remote")
-	repURL := fmt.Sprintf("%v/%v/%v", host, rep.Owner, rep.Name)
-	err = addRemote(repURL, dir, "origin")
-	CheckIfError(err)
-
-	log.Printf("push files to %v repository\n", repURL)
-	u, err := url.Parse(gogs.APPUrl)
-	CheckIfError(err)
-
-	u.Path = rep.Owner + "/" + rep.Name
-	u.User = url.UserPassword(gogs.AdminName, gogs.AdminPasswd)
-	url := u.String()
-	err = push(dir, url)
-	CheckIfError(err)
-}
-
-// clone repository in a given directory
-func clone(url, dir, name string) error {
-	cmd := newCMD("git", "clone", url, name)
-	cmd.Dir = dir
-	err := cmd.Run()
+	l.Println("initializing new repository")
+	err = initRepo(dir)
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-// commitAll creates a commit adding all the changed files in a repository
-func commitAll(dir, msg string) error {
-	// adds all files to staging area
-	cmd := newCMD("git", "add", ".")
-	cmd.Dir = dir
-	err := cmd.Run()
+	l.Println("commit files")
+	err = commitAll(dir, "Initial code", gitAuthor(rep, gogs))
 	if err != nil {
 		return err
 	}
 
-	// commit files
-	cmd = newCMD("git", "commit", "-m", msg)
-	cmd.Dir = dir
-	err = cmd.Run()
+	l.Println("adding remote")
+	repURL := fmt.Sprintf("%v/%v/%v", host, rep.Owner, rep.Name)
+	err = addRemote(repURL, dir, "origin")
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-// push makes a push in a repository
-func push(dir, url string) error {
-	cmd := newCMD("git", "push", url)
-	cmd.Dir = dir
-	err := cmd.Run()
+	l.Printf("push files to %v repository\n", repURL)
+	url, err := pushURL(rep, gogs)
 	if err != nil {
 		return err
 	}
-	return nil
+	return push(dir, url, gogsAuth(gogs))
 }
 
-// initRepo initializes a new repository
-func initRepo(dir string) error {
-	cmd := newCMD("git", "init")
-	cmd.Dir = dir
-	err := cmd.Run()
+// pushURL returns the url to push to a gogs repository,
+// the credentials are not part of the url see gogsAuth
+func pushURL(rep Repository, gogs InitData) (string, error) {
+	u, err := url.Parse(gogs.APPUrl)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	u.Path = rep.Owner + "/" + rep.Name
+	return u.String(), nil
 }
 
-// addRemote adds a new remote url in a repository
-func addRemote(url, dir, remote string) error {
-	cmd := newCMD("git", "remote", "add", remote, url)
-	cmd.Dir = dir
-	err := cmd.Run()
-	if err != nil {
-		return err
-	}
-	return nil
+// newCMD returns a new cmd and redirects the stderr to the logger
+func newCMD(l *log.Logger, name string, arg ...string) *exec.Cmd {
+	cmd := exec.Command(name, arg...)
+	cmd.Stderr = logWriter{l}
+	return cmd
 }
 
-// configGit configs user name and email, only for the current repository
-func configGit(user, email string) error {
-	// config name
-	cmd := newCMD("git", "config", "--global", "user.name", user)
-	err := cmd.Run()
-	if err != nil {
-		return err
-	}
-
-	// config email
-	cmd = newCMD("git", "config", "--global", "user.email", email)
-	err = cmd.Run()
-	if err != nil {
-		return err
-	}
-
-	return nil
+// logWriter writes the output of a cmd in a logger
+type logWriter struct {
+	l *log.Logger
 }
 
-// newCMD returns a new cmd and redirects the stderr to stdout
-func newCMD(name string, arg ...string) *exec.Cmd {
-	cmd := exec.Command(name, arg...)
-	cmd.Stderr = os.Stdout
-	return cmd
+func (w logWriter) Write(p []byte) (int, error) {
+	w.l.Print(string(p))
+	return len(p), nil
 }
 
 func main() {
 	// environment variables
 	configFile := cms.GetEnv(gogsConfigFileEnv, "examples/configFile.json")
 	host := cms.GetEnv(gogsHostEnv, "http://localhost:8181")
+	concurrency, err := strconv.Atoi(cms.GetEnv(gogsConcurrencyEnv, "1"))
+	if err != nil {
+		log.Fatalf("invalid %v value %v", gogsConcurrencyEnv, err.Error())
+	}
+
+	mode := cms.GetEnv(gogsModeEnv, "apply")
+	if mode != "apply" && mode != "export" {
+		log.Fatalf("invalid %v value %v", gogsModeEnv, mode)
+	}
 
 	// read config file
 	data := FileConfig{}
-	err := cms.DecodeFromFile(configFile, &data)
-	if err != nil {
-		log.Fatalf("reading config file %v", err.Error())
+	if mode == "apply" {
+		err = cms.DecodeFromFile(configFile, &data)
+		if err != nil {
+			log.Fatalf("reading config file %v", err.Error())
+		}
 	}
 
 	// checks and waits for gogs
@@ -420,9 +331,24 @@ func main() {
 		log.Println("host not ready, 3s")
 	}
 
-	// read user
-	user := data.InitData.AdminName
-	pass := data.InitData.AdminPasswd
+	if mode == "export" {
+		file := cms.GetEnv(gogsExportFileEnv, "configFile.json")
+		user := cms.GetEnv(gogsUserEnv, "")
+		if user == "" {
+			log.Fatalf("%v is required to export", gogsUserEnv)
+		}
+		export, err := exportConfig(user, cms.GetEnv(gogsPassEnv, ""), host)
+		if err != nil {
+			log.Fatalf("exporting gogs %s", err.Error())
+		}
+		err = cms.EncodeToFile(file, export, 0644)
+		if err != nil {
+			log.Fatalf("writing export file %v", err.Error())
+		}
+		log.Printf("%d organizations and %d repositories exported to %v\n",
+			len(export.Organizations), len(export.Repositories), file)
+		return
+	}
 
 	// post gogs setup
 	log.Println("initializing gogs")
@@ -450,49 +376,20 @@ func main() {
 	}
 	log.Println("initial configuration done!")
 
-	// post organizations
-	url = fmt.Sprintf("%v/api/v1/admin/users/%v/orgs", host, user)
-	for _, org := range data.Organizations {
-		log.Printf("creating %s organization", org.Username)
-		err = gogsPost(user, pass, url, org)
-		if err != nil {
-			log.Fatalf("error creating %s organization %s\n", org.Username, err.Error())
+	// post organizations and repositories
+	log.Printf("creating %d organizations and %d repositories, %d at the same time\n",
+		len(data.Organizations), len(data.Repositories), concurrency)
+	tasks := provisionTasks(data, host)
+	cms.RunTasks(tasks, concurrency)
+	failed := 0
+	for _, t := range tasks {
+		if t.Err != nil {
+			log.Printf("error in %v: %v\n", t.Name, t.Err.Error())
+			failed++
 		}
 	}
-
-	// post repositories
-	for _, rep := range data.Repositories {
-		log.Printf("creating %s repository", rep.Name)
-		// if there is no value for the owner, it will use the admin user as the repository owner
-		owner := rep.Owner
-		if rep.Owner == "" {
-			owner = user
-		}
-		rep.AutoInit = false
-		rep.Readme = "Default"
-		// if there is no value for add code to the repository it will be initialized
-		if rep.ContentSetupType == "" || rep.ContentSetupType == "empty" {
-			rep.AutoInit = true
-		}
-		url = fmt.Sprintf("%v/api/v1/admin/users/%v/repos", host, owner)
-		err = gogsPost(user, pass, url, rep)
-		if err != nil {
-			log.Fatalf("error creating %s repository %s\n", rep.Name, err.Error())
-		}
-		// add code to the repository
-		if rep.ContentSetupType != "" && rep.ContentSetupType != "empty" {
-			err = addCode(rep, data.InitData, host)
-			if err != nil {
-				log.Fatalf("error adding code to %v repository %s\n", rep.Name, err.Error())
-			}
-		}
+	if failed > 0 {
+		log.Fatalf("%d of %d tasks failed\n", failed, len(tasks))
 	}
 	log.Println("the job is done!")
 }
-
-// CheckIfError is used to call a log.Fatalf if an error is not nil
-func CheckIfError(err error) {
-	if err != nil {
-		log.Fatalf("error %s\n", err.Error())
-	}
-}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// provisionTasks returns the tasks to create the organizations and repositories,
+// a repository owned by an organization of the same config waits for its creation
+func provisionTasks(data FileConfig, host string) []*cms.Task {
+	user := data.InitData.AdminName
+	pass := data.InitData.AdminPasswd
+	total := len(data.Organizations) + len(data.Repositories)
+	tasks := []*cms.Task{}
+
+	orgs := map[string]*cms.Task{}
+	url := fmt.Sprintf("%v/api/v1/admin/users/%v/orgs", host, user)
+	for _, org := range data.Organizations {
+		org := org
+		l := taskLogger(len(tasks)+1, total, org.Username)
+		t := cms.NewTask("organization "+org.Username, func() error {
+			l.Printf("creating %s organization", org.Username)
+			err := gogsPost(user, pass, url, org)
+			if err != nil {
+				return fmt.Errorf("error creating %s organization %s", org.Username, err.Error())
+			}
+			return nil
+		})
+		// gogs user and organization names are case insensitive
+		orgs[strings.ToLower(org.Username)] = t
+		tasks = append(tasks, t)
+	}
+
+	for _, rep := range data.Repositories {
+		rep := rep
+		// if there is no value for the owner, it will use the admin user as the repository owner
+		if rep.Owner == "" {
+			rep.Owner = user
+		}
+		deps := []*cms.Task{}
+		if org, ok := orgs[strings.ToLower(rep.Owner)]; ok {
+			deps = append(deps, org)
+		}
+		l := taskLogger(len(tasks)+1, total, rep.Owner+"/"+rep.Name)
+		tasks = append(tasks, cms.NewTask("repository "+rep.Owner+"/"+rep.Name, func() error {
+			return provisionRepo(rep, data.InitData, host, l)
+		}, deps...))
+	}
+	return tasks
+}
+
+// provisionRepo creates a repository in gogs and adds its content
+func provisionRepo(rep Repository, gogs InitData, host string, l *log.Logger) error {
+	l.Printf("creating %s repository", rep.Name)
+	rep.AutoInit = false
+	rep.Readme = "Default"
+	// if there is no value for add code to the repository it will be initialized
+	if rep.ContentSetupType == "" || rep.ContentSetupType == "empty" {
+		rep.AutoInit = true
+	}
+	url := fmt.Sprintf("%v/api/v1/admin/users/%v/repos", host, rep.Owner)
+	err := gogsPost(gogs.AdminName, gogs.AdminPasswd, url, rep)
+	if err != nil {
+		return fmt.Errorf("error creating %s repository %s", rep.Name, err.Error())
+	}
+	if rep.AutoInit {
+		return nil
+	}
+
+	// add code to the repository
+	err = addCode(rep, gogs, host, l)
+	if err != nil {
+		return fmt.Errorf("error adding code to %v repository %s", rep.Name, err.Error())
+	}
+	l.Printf("%s repository created", rep.Name)
+	return nil
+}
+
+// taskLogger returns a logger with the task number and name as prefix,
+// the number has the same width for all the tasks to keep the output aligned
+func taskLogger(n, total int, name string) *log.Logger {
+	width := len(strconv.Itoa(total))
+	prefix := fmt.Sprintf("[%0*d/%d %v] ", width, n, total, name)
+	return log.New(os.Stderr, prefix, log.LstdFlags|log.Lmsgprefix)
+}
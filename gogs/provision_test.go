@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// testLogger discards the output of the functions under test
+var testLogger = log.New(ioutil.Discard, "", 0)
+
+// fakeGogs records the organizations and repositories created
+type fakeGogs struct {
+	mu    sync.Mutex
+	orgs  map[string]bool
+	repos []string
+	// errors contains the repositories created before their owner
+	errors []string
+}
+
+func (f *fakeGogs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, pass, _ := r.BasicAuth()
+	if user != "tikal" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	owner := parts[len(parts)-2]
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/orgs"):
+		org := Organization{}
+		json.NewDecoder(r.Body).Decode(&org)
+		// slow organizations to detect repositories created before their owner
+		time.Sleep(20 * time.Millisecond)
+		f.orgs[org.Username] = true
+	case strings.HasSuffix(r.URL.Path, "/repos"):
+		rep := Repository{}
+		json.NewDecoder(r.Body).Decode(&rep)
+		if owner != "tikal" && !f.orgs[owner] {
+			f.errors = append(f.errors, owner+"/"+rep.Name)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.repos = append(f.repos, owner+"/"+rep.Name)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func TestProvisionTasks(t *testing.T) {
+	gogs := &fakeGogs{orgs: map[string]bool{}}
+	srv := httptest.NewServer(gogs)
+	defer srv.Close()
+
+	data := FileConfig{
+		InitData:      InitData{AdminName: "tikal", AdminPasswd: "secret"},
+		Organizations: []Organization{{Username: "orgA"}, {Username: "orgB"}},
+		Repositories: []Repository{
+			{Name: "one", Owner: "orgA"},
+			{Name: "two", Owner: "orgB"},
+			{Name: "three"},
+			{Name: "four", Owner: "orgA", ContentSetupType: "empty"},
+		},
+	}
+	tasks := provisionTasks(data, srv.URL)
+	if len(tasks) != 6 {
+		t.Fatalf("tasks got: %d want: 6", len(tasks))
+	}
+	cms.RunTasks(tasks, 4)
+
+	for _, task := range tasks {
+		if task.Err != nil {
+			t.Errorf("unexpected error in %v: %v", task.Name, task.Err)
+		}
+	}
+	if len(gogs.errors) > 0 {
+		t.Errorf("repositories created before their owner: %v", gogs.errors)
+	}
+	if len(gogs.repos) != 4 {
+		t.Errorf("repositories created got: %v", gogs.repos)
+	}
+	if tasks[4].Name != "repository tikal/three" {
+		t.Errorf("repository without owner must use the admin user got: %v", tasks[4].Name)
+	}
+}
+
+func TestProvisionTasksFailedOrganization(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	data := FileConfig{
+		InitData:      InitData{AdminName: "tikal", AdminPasswd: "secret"},
+		Organizations: []Organization{{Username: "orgA"}},
+		Repositories:  []Repository{{Name: "one", Owner: "ORGA"}},
+	}
+	tasks := provisionTasks(data, srv.URL)
+	cms.RunTasks(tasks, 2)
+	if tasks[1].Err == nil || !strings.Contains(tasks[1].Err.Error(), "organization orgA failed") {
+		t.Errorf("repository must fail when its owner fails got: %v", tasks[1].Err)
+	}
+}
+
+func TestTaskLogger(t *testing.T) {
+	l := taskLogger(3, 12, "myOrg/hello-world")
+	if l.Prefix() != "[03/12 myOrg/hello-world] " {
+		t.Errorf("unexpected prefix got: %q", l.Prefix())
+	}
+}
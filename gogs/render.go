@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// renderData represents the values available in the templates
+type renderData struct {
+	Repo Repository
+	Init InitData
+	Env  map[string]string
+	Vars map[string]string
+}
+
+// renderContent renders the templates and applies the replacements
+// configured in the repository to the files inside dir
+func renderContent(rep Repository, gogs InitData, dir string, l *log.Logger) error {
+	r := rep.Render
+	if len(r.Templates) == 0 && len(r.Replacements) == 0 {
+		return nil
+	}
+
+	if len(r.Delims) != 0 && len(r.Delims) != 2 {
+		return fmt.Errorf("delims requires the left and right delimiters got %v", r.Delims)
+	}
+	files, err := matchFiles(dir, r.Templates)
+	if err != nil {
+		return err
+	}
+
+	// the templates end up in the repository, keep the secrets out of them
+	rep.GitImport.Username = ""
+	rep.GitImport.Password = ""
+	gogs.AdminPasswd = ""
+	gogs.AdminConfirmPasswd = ""
+	data := renderData{Repo: rep, Init: gogs, Env: envMap(r.Env), Vars: r.Vars}
+	for _, f := range files {
+		l.Printf("rendering %v template\n", f)
+		err = renderFile(filepath.Join(dir, f), r.Delims, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, rp := range r.Replacements {
+		err = replaceFiles(dir, rp, l)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderFile executes a file as a go template and writes the result in the same file
+func renderFile(file string, delims []string, data renderData) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	tmpl := template.New(filepath.Base(file)).Option("missingkey=error")
+	if len(delims) == 2 {
+		tmpl = tmpl.Delims(delims[0], delims[1])
+	}
+	tmpl, err = tmpl.Parse(string(content))
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, out.Bytes(), 0)
+}
+
+// replaceFiles applies a replacement to the files matching its patterns
+func replaceFiles(dir string, rp Replacement, l *log.Logger) error {
+	if rp.Old == "" {
+		return fmt.Errorf("replacement for %v requires the old value", rp.Files)
+	}
+	var re *regexp.Regexp
+	if rp.Regex {
+		var err error
+		re, err = regexp.Compile(rp.Old)
+		if err != nil {
+			return err
+		}
+	}
+
+	files, err := matchFiles(dir, rp.Files)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		l.Printf("replacing %v in %v\n", rp.Old, f)
+		if re != nil {
+			err = cms.ReplaceRegex(filepath.Join(dir, f), re, rp.New)
+		} else {
+			err = cms.ReplaceStr(filepath.Join(dir, f), rp.Old, rp.New)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchFiles returns the regular files inside dir matching any of the glob patterns,
+// patterns with a slash match the path relative to dir and patterns without
+// a slash match the file name in any directory, the .git directory is skipped
+func matchFiles(dir string, patterns []string) ([]string, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %v: %v", p, err)
+		}
+	}
+
+	files := []string{}
+	if len(patterns) == 0 {
+		return files, nil
+	}
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, p := range patterns {
+			name := rel
+			if !strings.Contains(p, "/") {
+				name = path.Base(rel)
+			}
+			if ok, _ := path.Match(p, name); ok {
+				files = append(files, rel)
+				break
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// envMap returns the given environment variables as a map, unset variables are omitted
+func envMap(names []string) map[string]string {
+	env := map[string]string{}
+	for _, name := range names {
+		if v, ok := os.LookupEnv(name); ok {
+			env[name] = v
+		}
+	}
+	return env
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRenderContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("RENDER_TEST_VAR", "from-env")
+
+	files := map[string]string{
+		"README.md":              "# {{.Repo.Name}} by {{.Init.AdminName}}{{.Init.AdminPasswd}} {{.Vars.team}} {{.Env.RENDER_TEST_VAR}}",
+		"docs/README.md":         "{{.Repo.Owner}}",
+		"ci/build.yml":           "run: ${{ matrix.os }} [[.Repo.Name]]",
+		"maven/settings.xml":     "<url>PROJECT_REPOSITORY_GROUP_URL</url><version>1.0.0</version>",
+		"app/pom.xml":            "<version>1.0.0</version>",
+		".git/README.md":         "{{.Missing}}",
+		"untouched/template.txt": "{{.Repo.Name}}",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	rep := Repository{
+		Name:  "hello-world",
+		Owner: "myOrg",
+		Render: Render{
+			Templates: []string{"README.md"},
+			Vars:      map[string]string{"team": "devops"},
+			Env:       []string{"RENDER_TEST_VAR"},
+			Replacements: []Replacement{
+				{Files: []string{"maven/settings.xml"}, Old: "PROJECT_REPOSITORY_GROUP_URL", New: "http://nexus/repository/group"},
+				{Files: []string{"*.xml"}, Old: `<version>(\d+)\.0\.0</version>`, New: "<version>$1.2.0</version>", Regex: true},
+			},
+		},
+	}
+	err = renderContent(rep, InitData{AdminName: "tikal", AdminPasswd: "secret"}, dir, testLogger)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// custom delimiters
+	rep.Render = Render{Templates: []string{"ci/*.yml"}, Delims: []string{"[[", "]]"}}
+	err = renderContent(rep, InitData{}, dir, testLogger)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	want := map[string]string{
+		"README.md":              "# hello-world by tikal devops from-env",
+		"docs/README.md":         "myOrg",
+		"ci/build.yml":           "run: ${{ matrix.os }} hello-world",
+		"maven/settings.xml":     "<url>http://nexus/repository/group</url><version>1.2.0</version>",
+		"app/pom.xml":            "<version>1.2.0</version>",
+		".git/README.md":         "{{.Missing}}",
+		"untouched/template.txt": "{{.Repo.Name}}",
+	}
+	for name, content := range want {
+		got, _ := ioutil.ReadFile(filepath.Join(dir, name))
+		if string(got) != content {
+			t.Errorf("%v contains %s and want: %v", name, got, content)
+		}
+	}
+}
+
+func TestRenderContentErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "render")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("{{.Vars.missing}}"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "env.md"), []byte("{{.Env.PATH}}"), 0644)
+
+	tests := map[string]Render{
+		"missing key":   {Templates: []string{"README.md"}},
+		"not allowed":   {Templates: []string{"env.md"}},
+		"one delim":     {Delims: []string{"[["}, Replacements: []Replacement{{Files: []string{"*.txt"}, Old: "x"}}},
+		"bad pattern":   {Templates: []string{"[README.md"}},
+		"bad regex":     {Replacements: []Replacement{{Files: []string{"*.md"}, Old: "(", Regex: true}}},
+		"without value": {Replacements: []Replacement{{Files: []string{"*.md"}}}},
+	}
+	for name, r := range tests {
+		if renderContent(Repository{Render: r}, InitData{}, dir, testLogger) == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+}
+
+func TestMatchFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "match")
+	if err != nil {
+		t.Fatal("not possible to create dir")
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/c.md"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+
+	files, err := matchFiles(dir, []string{"*.txt", "sub/*.md"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := []string{"a.txt", "sub/b.txt", "sub/c.md"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("matchFiles got: %v want: %v", files, want)
+	}
+}
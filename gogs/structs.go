@@ -40,10 +40,18 @@ type Repository struct {
 	// desired readme template name to apply in the initial commit
 	Readme string `json:"readme"`
 	// ContentSetupType represents the type of content that will have the repository
-	ContentSetupType string   `json:"content_setup_type"`
-	DantaAEM         DantaAEM `json:"danta_aem_archetype"`
-	EP               EP       `json:"ep_commerce"`
-	BR               BR       `json:"bloomreach_archetype"`
+	ContentSetupType string         `json:"content_setup_type"`
+	DantaAEM         DantaAEM       `json:"danta_aem_archetype"`
+	EP               EP             `json:"ep_commerce"`
+	BR               BR             `json:"bloomreach_archetype"`
+	Exec             Exec           `json:"exec"`
+	GitImport        GitImport      `json:"git_import"`
+	Archive          Archive        `json:"archive"`
+	MavenArchetype   MavenArchetype `json:"maven_archetype"`
+	// Render is applied to the content before the initial commit
+	Render Render `json:"render"`
+	// Author of the initial commit, the admin user by default
+	Author GitAuthor `json:"author"`
 }
 
 // DantaAEM represents a configuration data to create a project with Danta AEM archetype
@@ -77,3 +85,90 @@ type BR struct {
 	Package          string `json:"package"`
 	ProjectName      string `json:"project_name"`
 }
+
+// Exec represents a configuration to create a project running an external executable,
+// the executable receives the repository configuration as JSON on stdin
+// and the target directory as its last argument
+type Exec struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	// Timeout in seconds to wait for the executable, 600 by default
+	Timeout int `json:"timeout"`
+	// OutputDir is the directory inside the target directory to push,
+	// empty to push the whole target directory
+	OutputDir string `json:"output_dir"`
+}
+
+// GitImport represents a configuration to create a project from an existing git repository
+type GitImport struct {
+	// URL of the source repository, any url supported by git e.g. file:///path/repo.git
+	URL string `json:"url"`
+	// Ref is the branch, tag or commit to import, HEAD by default
+	Ref string `json:"ref"`
+	// Depth limits the fetch to the given number of commits, 0 fetches the full history
+	Depth int `json:"depth"`
+	// KeepHistory pushes all the branches and tags of the source repository
+	// instead of a single "Initial code" commit, Ref and Depth are ignored
+	KeepHistory bool `json:"keep_history"`
+	// Username and Password authenticate http(s) urls, an empty
+	// Password with a Username is read from GIT_IMPORT_PASSWORD
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Archive represents a configuration to create a project from a zip, tar, tar.gz or tar.zst archive
+type Archive struct {
+	// Source is a local path or a http(s) url of the archive
+	Source string `json:"source"`
+	// SHA256 is the expected checksum of the archive, empty to skip the verification
+	SHA256 string `json:"sha256"`
+	// StripComponents removes the given number of leading directories from the file names
+	StripComponents int `json:"strip_components"`
+	// Subdir extracts only the given directory of the archive, applied after StripComponents
+	Subdir string `json:"subdir"`
+	// Timeout in seconds to download the archive, 600 by default
+	Timeout int `json:"timeout"`
+}
+
+// MavenArchetype represents a configuration data to create a project with any maven archetype
+type MavenArchetype struct {
+	GroupID    string `json:"archetype_group" validate:"required"`
+	ArtifactID string `json:"archetype_artifact" validate:"required"`
+	Version    string `json:"archetype_version" validate:"required"`
+	// Repository is the remote repository of the archetype, empty to use the maven configuration
+	Repository string `json:"archetype_repository"`
+	// Properties are passed to the archetype as -Dkey=value e.g. groupId, artifactId, package
+	Properties map[string]string `json:"properties"`
+	// OutputDir is the generated directory to push, the artifactId property by default
+	OutputDir string `json:"output_dir"`
+}
+
+// Render represents a post-processing of the repository content
+type Render struct {
+	// Templates are glob patterns of the files to render as go templates,
+	// the templates can use .Repo, .Init, .Env and .Vars
+	Templates []string `json:"templates"`
+	// Delims are the left and right template delimiters, {{ and }} by default
+	Delims []string `json:"delims"`
+	// Env are the names of the environment variables available as .Env
+	Env []string `json:"env"`
+	// Vars are custom values available in the templates as .Vars
+	Vars         map[string]string `json:"vars"`
+	Replacements []Replacement     `json:"replacements"`
+}
+
+// Replacement represents a text replacement in the files matching the glob patterns
+type Replacement struct {
+	Files []string `json:"files"`
+	Old   string   `json:"old"`
+	New   string   `json:"new"`
+	// Regex set to true to use Old as a regular expression, New can contain $1 expansions
+	Regex bool `json:"regex"`
+}
+
+// GitAuthor represents the identity used in the commits of a repository
+type GitAuthor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+)
+
+// CleanupPolicy represents a cleanup policy in the nexus REST API
+type CleanupPolicy struct {
+	Name                    string `json:"name"`
+	Notes                   string `json:"notes"`
+	Format                  string `json:"format"`
+	CriteriaLastDownloaded  int    `json:"criteriaLastDownloaded,omitempty"`
+	CriteriaLastBlobUpdated int    `json:"criteriaLastBlobUpdated,omitempty"`
+	CriteriaReleaseType     string `json:"criteriaReleaseType,omitempty"`
+	CriteriaAssetRegex      string `json:"criteriaAssetRegex,omitempty"`
+}
+
+// extDirectCleanupPolicy represents a cleanup policy in the coreui_CleanupPolicy
+// ExtDirect methods, it has the same criteria fields than the REST API
+type extDirectCleanupPolicy struct {
+	CleanupPolicy
+	Mode string `json:"mode"`
+}
+
+// validateCleanupPolicies checks the names and criteria of the cleanup policies and
+// that the policies of the repositories are in the config or existing in nexus.
+// When existing is nil the policies out of the config are not verified
+func validateCleanupPolicies(c ArtifactoryConfig, existing map[string]bool) error {
+	names := map[string]bool{}
+	for _, p := range c.CleanupPolicies {
+		if p.Name == "" {
+			return fmt.Errorf("cleanup policy without name")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("cleanup policy %v is defined more than once", p.Name)
+		}
+		names[p.Name] = true
+		if p.LastDownloaded == 0 && p.LastBlobUpdated == 0 && p.ReleaseType == "" && p.AssetRegex == "" {
+			return fmt.Errorf("cleanup policy %v without criteria", p.Name)
+		}
+		if p.ReleaseType != "" && p.ReleaseType != "RELEASES" && p.ReleaseType != "PRERELEASES" {
+			return fmt.Errorf("cleanup policy %v has an unknown release type %v", p.Name, p.ReleaseType)
+		}
+		if _, err := regexp.Compile(p.AssetRegex); err != nil {
+			return fmt.Errorf("cleanup policy %v has an invalid asset regex %v", p.Name, err)
+		}
+	}
+
+	for _, h := range c.Hosteds {
+		if p := unknownCleanupPolicy(h.CleanupPolicies, names, existing); p != "" {
+			return fmt.Errorf("repository %v has an unknown cleanup policy %v", h.Name, p)
+		}
+	}
+	for _, px := range c.Proxies {
+		if p := unknownCleanupPolicy(px.CleanupPolicies, names, existing); p != "" {
+			return fmt.Errorf("repository %v has an unknown cleanup policy %v", px.Name, p)
+		}
+	}
+	return nil
+}
+
+// unknownCleanupPolicy returns the first policy out of names and existing
+func unknownCleanupPolicy(policies []string, names, existing map[string]bool) string {
+	for _, p := range policies {
+		if !names[p] && existing != nil && !existing[p] {
+			return p
+		}
+	}
+	return ""
+}
+
+// cleanupPolicyFromConfig returns the cleanup policy of the config
+func cleanupPolicyFromConfig(p ArtifactoryCleanupPolicy) CleanupPolicy {
+	policy := CleanupPolicy{
+		Name:                    p.Name,
+		Notes:                   p.Notes,
+		Format:                  p.Format,
+		CriteriaLastDownloaded:  p.LastDownloaded,
+		CriteriaLastBlobUpdated: p.LastBlobUpdated,
+		CriteriaReleaseType:     p.ReleaseType,
+		CriteriaAssetRegex:      p.AssetRegex,
+	}
+	if policy.Format == "" {
+		policy.Format = "ALL_FORMATS"
+	}
+	return policy
+}
+
+// applyCleanupPolicies creates the cleanup policies of the config or updates them
+// when they already exist, nexus versions without the REST cleanup policies API use ExtDirect
+func (c *nexusClient) applyCleanupPolicies(policies []ArtifactoryCleanupPolicy) error {
+	if len(policies) == 0 {
+		return nil
+	}
+	existing, extDirect, err := c.cleanupPolicies()
+	if err != nil {
+		return err
+	}
+	for _, p := range policies {
+		policy := cleanupPolicyFromConfig(p)
+		err = c.saveCleanupPolicy(policy, existing[policy.Name], extDirect)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanupPolicies returns the names of the cleanup policies in nexus,
+// extDirect is true when the REST cleanup policies API is not available
+func (c *nexusClient) cleanupPolicies() (names map[string]bool, extDirect bool, err error) {
+	policies := []struct {
+		Name string `json:"name"`
+	}{}
+	extDirect = c.extDirect
+	if !extDirect {
+		err = c.do(http.MethodGet, "/v1/cleanup-policies", nil, &policies)
+		if e, ok := err.(*StatusError); ok && e.Code == http.StatusNotFound {
+			extDirect = true
+		} else if err != nil {
+			return nil, false, err
+		}
+	}
+	if extDirect {
+		resp, err := c.call("coreui_CleanupPolicy", "read")
+		if err != nil {
+			return nil, true, err
+		}
+		err = json.Unmarshal(resp.Result.Data, &policies)
+		if err != nil {
+			return nil, true, err
+		}
+	}
+
+	names = map[string]bool{}
+	for _, p := range policies {
+		names[p.Name] = true
+	}
+	return names, extDirect, nil
+}
+
+// saveCleanupPolicy creates the cleanup policy or updates it when it exists
+func (c *nexusClient) saveCleanupPolicy(p CleanupPolicy, exists, extDirect bool) error {
+	action := "created"
+	if exists {
+		action = "updated"
+	}
+	var err error
+	switch {
+	case extDirect && exists:
+		_, err = c.call("coreui_CleanupPolicy", "update", toExtDirectCleanupPolicy(p))
+	case extDirect:
+		_, err = c.call("coreui_CleanupPolicy", "create", toExtDirectCleanupPolicy(p))
+	case exists:
+		err = c.do(http.MethodPut, "/v1/cleanup-policies/"+p.Name, p, nil)
+	default:
+		err = c.do(http.MethodPost, "/v1/cleanup-policies", p, nil)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("cleanup policy %v %v", p.Name, action)
+	return nil
+}
+
+func toExtDirectCleanupPolicy(p CleanupPolicy) extDirectCleanupPolicy {
+	return extDirectCleanupPolicy{CleanupPolicy: p, Mode: "delete"}
+}
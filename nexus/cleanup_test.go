@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var cleanupConfig = ArtifactoryConfig{
+	CleanupPolicies: []ArtifactoryCleanupPolicy{
+		{Name: "snapshots", Format: "maven2", LastBlobUpdated: 30, ReleaseType: "PRERELEASES"},
+		{Name: "old-downloads", LastDownloaded: 90, AssetRegex: `.*\.zip`},
+	},
+	Hosteds: []ArtifactoryHosted{{Name: "maven-snapshots", CleanupPolicies: []string{"snapshots"}}},
+}
+
+func TestValidateCleanupPolicies(t *testing.T) {
+	tests := map[string][]ArtifactoryCleanupPolicy{
+		"without name":     {{LastDownloaded: 1}},
+		"duplicated":       {{Name: "a", LastDownloaded: 1}, {Name: "a", LastDownloaded: 1}},
+		"without criteria": {{Name: "a"}},
+		"release type":     {{Name: "a", ReleaseType: "SNAPSHOTS"}},
+		"asset regex":      {{Name: "a", AssetRegex: "("}},
+	}
+	for name, policies := range tests {
+		if validateCleanupPolicies(ArtifactoryConfig{CleanupPolicies: policies}, nil) == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+	if err := validateCleanupPolicies(cleanupConfig, map[string]bool{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// the policies of the repositories are in the config or in nexus
+	c := ArtifactoryConfig{Proxies: []ArtifactoryProxy{{Name: "central", CleanupPolicies: []string{"existing"}}}}
+	if err := validateCleanupPolicies(c, nil); err != nil {
+		t.Errorf("unexpected error without existing policies %v", err)
+	}
+	if err := validateCleanupPolicies(c, map[string]bool{"existing": true}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if validateCleanupPolicies(c, map[string]bool{}) == nil {
+		t.Error("must return an error with an unknown policy")
+	}
+}
+
+// fakeCleanupPolicies serves the cleanup policies of a fakeNexus with ExtDirect
+// and with the REST API only when rest is true
+type fakeCleanupPolicies struct {
+	mu       sync.Mutex
+	policies map[string]bool
+}
+
+func newFakeCleanupPolicies(f *fakeNexus, rest bool) *fakeCleanupPolicies {
+	p := &fakeCleanupPolicies{policies: map[string]bool{}}
+	if rest {
+		f.handle("/service/rest/v1/cleanup-policies", p.rest)
+	}
+	f.handleAction("coreui_CleanupPolicy", p.extDirect)
+	return p
+}
+
+func (p *fakeCleanupPolicies) rest(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/service/rest/v1/cleanup-policies"), "/")
+	policy := CleanupPolicy{}
+	json.NewDecoder(r.Body).Decode(&policy)
+	switch r.Method {
+	case http.MethodGet:
+		list := []CleanupPolicy{}
+		for name := range p.policies {
+			list = append(list, CleanupPolicy{Name: name})
+		}
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		if p.policies[policy.Name] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p.policies[policy.Name] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		if !p.policies[name] {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (p *fakeCleanupPolicies) extDirect(method string, data []json.RawMessage) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if method == "read" {
+		list := []extDirectCleanupPolicy{}
+		for name := range p.policies {
+			list = append(list, extDirectCleanupPolicy{CleanupPolicy: CleanupPolicy{Name: name}})
+		}
+		return list, nil
+	}
+	policy := extDirectCleanupPolicy{}
+	json.Unmarshal(data[0], &policy)
+	if p.policies[policy.Name] == (method == "create") {
+		return nil, fmt.Errorf("invalid method %v", method)
+	}
+	p.policies[policy.Name] = true
+	return nil, nil
+}
+
+func TestApplyCleanupPolicies(t *testing.T) {
+	for _, rest := range []bool{true, false} {
+		nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+		newFakeCleanupPolicies(nexus, rest)
+		srv := httptest.NewServer(nexus)
+		client, err := newNexusClient("admin", "admin123", srv.URL)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		// the second run updates the policies
+		for i := 0; i < 2; i++ {
+			if err = client.applyCleanupPolicies(cleanupConfig.CleanupPolicies); err != nil {
+				t.Errorf("rest %v: unexpected error %v", rest, err)
+			}
+		}
+
+		created := nexus.bodies("POST /service/rest/v1/cleanup-policies")
+		updated := nexus.bodies("PUT /service/rest/v1/cleanup-policies/old-downloads")
+		dir := "rest"
+		if !rest {
+			created = nexus.bodies("coreui_CleanupPolicy.create")
+			updated = nexus.bodies("coreui_CleanupPolicy.update")[1:]
+			dir = "extdirect"
+		}
+		if len(created) != 2 || len(updated) != 1 {
+			t.Fatalf("rest %v: requests got: %v %v", rest, created, updated)
+		}
+		assertPayload(t, dir+"/cleanup_policy_create.json", created[0])
+		assertPayload(t, dir+"/cleanup_policy_update.json", updated[0])
+		srv.Close()
+	}
+}
+
+func TestRepositoryCleanup(t *testing.T) {
+	rep := hostedRepository(cleanupConfig.Hosteds[0])
+	body, _ := json.Marshal(rep)
+	data, _ := json.Marshal(dataConfig(rep).Attributes)
+	if !strings.Contains(string(body), `"cleanup":{"policyNames":["snapshots"]}`) ||
+		string(data) != `{"storage":{"blobStoreName":"default","strictContentTypeValidation":true,"writePolicy":"ALLOW"},"group":null,"maven":{"versionPolicy":"","layoutPolicy":""},"cleanup":{"policyName":["snapshots"]}}` {
+		t.Errorf("cleanup attributes got: %s %s", body, data)
+	}
+	if repositoryFromData(dataConfig(rep)).Cleanup.PolicyNames[0] != "snapshots" {
+		t.Error("cleanup policies must be read from ExtDirect")
+	}
+	if hostedRepository(ArtifactoryHosted{Name: "releases"}).Cleanup != nil {
+		t.Error("repositories without policies must not have cleanup attributes")
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+const (
+	formatMaven = "maven2"
+	typeHosted  = "hosted"
+	typeProxy   = "proxy"
+	typeGroup   = "group"
+)
+
+// restMinVersion is the first nexus version with the REST repositories API
+var restMinVersion = []int{3, 20}
+
+// serverVersion matches the version in the Server header e.g. Nexus/3.12.0-01 (OSS)
+var serverVersion = regexp.MustCompile(`Nexus/(\d+)\.(\d+)`)
+
+// nexusClient manages nexus resources using the REST API, the ExtDirect
+// endpoint is used with nexus versions without the REST repositories API
+type nexusClient struct {
+	user string
+	pass string
+	host string
+	// extDirect is true when the REST repositories API is not available
+	extDirect bool
+	client    *http.Client
+	tid       int32
+}
+
+// newNexusClient returns a client for the nexus in host detecting its version
+func newNexusClient(user, pass, host string) (*nexusClient, error) {
+	c := &nexusClient{
+		user:   user,
+		pass:   pass,
+		host:   host,
+		client: cms.GetClient(30),
+	}
+	req, err := http.NewRequest(http.MethodGet, host+"/service/rest/v1/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	server := resp.Header.Get("Server")
+	c.extDirect = !restSupported(server)
+	if c.extDirect {
+		log.Printf("nexus %v without REST repositories API, using ExtDirect", server)
+	}
+	return c, nil
+}
+
+// restSupported returns true when the Server header belongs to a nexus version
+// with the REST repositories API, unknown versions are expected to support it
+func restSupported(server string) bool {
+	m := serverVersion.FindStringSubmatch(server)
+	if m == nil {
+		return true
+	}
+	for i, min := range restMinVersion {
+		v, _ := strconv.Atoi(m[i+1])
+		if v != min {
+			return v > min
+		}
+	}
+	return true
+}
+
+// pathFormat returns the format used in the REST paths
+func pathFormat(format string) string {
+	if format == formatMaven {
+		return "maven"
+	}
+	return format
+}
+
+// do makes a request to the REST API encoding in and decoding the response into out
+func (c *nexusClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		jsonData, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+	req, err := http.NewRequest(method, c.host+"/service/rest"+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.user, c.pass)
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Method: method, URL: req.URL.String(), Code: resp.StatusCode, Body: string(data)}
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// call makes an ExtDirect request and returns the response
+func (c *nexusClient) call(action, method string, data ...interface{}) (Response, error) {
+	obj := NexusConfig{
+		Action: action,
+		Method: method,
+		Data:   data,
+		Type:   "rpc",
+		TID:    int(atomic.AddInt32(&c.tid, 1)),
+	}
+	if len(data) == 0 {
+		obj.Data = nil
+	}
+	return nexusCall(c.user, c.pass, c.host, obj)
+}
+
+// repositories returns the names of the repositories in nexus
+func (c *nexusClient) repositories() (map[string]bool, error) {
+	repos := []struct {
+		Name string `json:"name"`
+	}{}
+	if c.extDirect {
+		resp, err := c.call("coreui_Repository", "read")
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(resp.Result.Data, &repos)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := c.do(http.MethodGet, "/v1/repositories", nil, &repos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := map[string]bool{}
+	for _, r := range repos {
+		names[r.Name] = true
+	}
+	return names, nil
+}
+
+// getRepository returns the repository with the format, type and name
+func (c *nexusClient) getRepository(format, typ, name string) (Repository, error) {
+	if c.extDirect {
+		resp, err := c.call("coreui_Repository", "read")
+		if err != nil {
+			return Repository{}, err
+		}
+		repos := []DataConfig{}
+		err = json.Unmarshal(resp.Result.Data, &repos)
+		if err != nil {
+			return Repository{}, err
+		}
+		for _, d := range repos {
+			if d.Name == name && d.Format == format && d.Type == typ {
+				return repositoryFromData(d), nil
+			}
+		}
+		return Repository{}, fmt.Errorf("repository %v %v %v not found", format, typ, name)
+	}
+
+	r := Repository{}
+	err := c.do(http.MethodGet, "/v1/repositories/"+pathFormat(format)+"/"+typ+"/"+name, nil, &r)
+	r.Format = format
+	r.Type = typ
+	return r, err
+}
+
+// createRepository creates a new repository
+func (c *nexusClient) createRepository(r Repository) error {
+	if c.extDirect {
+		_, err := c.call("coreui_Repository", "create", dataConfig(r))
+		return err
+	}
+	return c.do(http.MethodPost, "/v1/repositories/"+pathFormat(r.Format)+"/"+r.Type, r, nil)
+}
+
+// updateRepository replaces the configuration of an existing repository
+func (c *nexusClient) updateRepository(r Repository) error {
+	if c.extDirect {
+		_, err := c.call("coreui_Repository", "update", dataConfig(r))
+		return err
+	}
+	return c.do(http.MethodPut, "/v1/repositories/"+pathFormat(r.Format)+"/"+r.Type+"/"+r.Name, r, nil)
+}
+
+// deleteRepository removes the repository with the name
+func (c *nexusClient) deleteRepository(name string) error {
+	if c.extDirect {
+		_, err := c.call("coreui_Repository", "remove", name)
+		return err
+	}
+	return c.do(http.MethodDelete, "/v1/repositories/"+name, nil, nil)
+}
+
+// dataConfig returns the ExtDirect representation of a repository
+func dataConfig(r Repository) DataConfig {
+	var cleanup *ExtDirectCleanup
+	if r.Cleanup != nil {
+		cleanup = &ExtDirectCleanup{PolicyName: r.Cleanup.PolicyNames}
+	}
+	return DataConfig{
+		Name:        r.Name,
+		Online:      r.Online,
+		AuthEnabled: r.HTTPClient != nil && r.HTTPClient.Authentication != nil,
+		Recipe:      r.Format + "-" + r.Type,
+		Attributes: Attributes{
+			Storage:       r.Storage,
+			Group:         r.Group,
+			Maven:         r.Maven,
+			Proxy:         r.Proxy,
+			HTTPClient:    r.HTTPClient,
+			NegativeCache: r.NegativeCache,
+			Cleanup:       cleanup,
+		},
+	}
+}
+
+// repositoryFromData returns the repository of an ExtDirect representation
+func repositoryFromData(d DataConfig) Repository {
+	var cleanup *Cleanup
+	if d.Attributes.Cleanup != nil {
+		cleanup = &Cleanup{PolicyNames: d.Attributes.Cleanup.PolicyName}
+	}
+	return Repository{
+		Name:          d.Name,
+		Format:        d.Format,
+		Type:          d.Type,
+		Online:        d.Online,
+		Storage:       d.Attributes.Storage,
+		Group:         d.Attributes.Group,
+		Maven:         d.Attributes.Maven,
+		Proxy:         d.Attributes.Proxy,
+		HTTPClient:    d.Attributes.HTTPClient,
+		NegativeCache: d.Attributes.NegativeCache,
+		Cleanup:       cleanup,
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNexus records the repositories created with the REST API or ExtDirect
+type fakeNexus struct {
+	mu     sync.Mutex
+	server string
+	repos  map[string]Repository
+	roles  map[string]Role
+	users  map[string]User
+	// passwords contains the password of each user
+	passwords map[string]string
+	// settings contains the body of the PUT requests by path
+	// and the data of the ExtDirect updates by action
+	settings map[string]string
+	// handlers serve the REST paths by prefix and actions the ExtDirect
+	// actions by name, or by action.method for a single method
+	handlers map[string]http.HandlerFunc
+	actions  map[string]extDirectAction
+	// requests contains the bodies received by "METHOD path" for
+	// the REST API and by "action.method" for ExtDirect
+	requests map[string][]string
+	// errors contains the groups created before their members
+	errors []string
+}
+
+// extDirectAction handles an ExtDirect method returning the result data
+type extDirectAction func(method string, data []json.RawMessage) (interface{}, error)
+
+func newFakeNexus(server string) *fakeNexus {
+	return &fakeNexus{
+		repos:     map[string]Repository{},
+		roles:     map[string]Role{},
+		users:     map[string]User{},
+		passwords: map[string]string{"admin": "admin123"},
+		settings:  map[string]string{},
+		handlers:  map[string]http.HandlerFunc{},
+		actions:   map[string]extDirectAction{},
+		requests:  map[string][]string{},
+		server:    server,
+	}
+}
+
+// handle serves the REST paths starting with prefix
+func (f *fakeNexus) handle(prefix string, h http.HandlerFunc) {
+	f.handlers[prefix] = h
+}
+
+// handleAction serves an ExtDirect action or a single action.method
+func (f *fakeNexus) handleAction(name string, h extDirectAction) {
+	f.actions[name] = h
+}
+
+// bodies returns the bodies received with a key of requests
+func (f *fakeNexus) bodies(key string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.requests[key]...)
+}
+
+// handler returns the registered handler with the longest prefix of path
+func (f *fakeNexus) handler(path string) http.HandlerFunc {
+	prefix := ""
+	for p := range f.handlers {
+		if strings.HasPrefix(path, p) && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	return f.handlers[prefix]
+}
+
+func (f *fakeNexus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", f.server)
+	user, pass, _ := r.BasicAuth()
+	f.mu.Lock()
+	valid := f.passwords[user] == pass
+	f.mu.Unlock()
+	if r.URL.Path != "/service/rest/v1/status" && (user != "admin" || !valid) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/service/extdirect" && r.Method != http.MethodGet {
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		key := r.Method + " " + r.URL.Path
+		f.requests[key] = append(f.requests[key], string(body))
+		f.mu.Unlock()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if h := f.handler(r.URL.Path); h != nil {
+		h(w, r)
+		return
+	}
+	switch {
+	case r.URL.Path == "/service/rest/v1/status":
+	case r.URL.Path == "/service/extdirect":
+		f.extDirect(w, r)
+	case strings.HasPrefix(r.URL.Path, "/service/rest/v1/repositories"):
+		f.rest(w, r)
+	case strings.HasPrefix(r.URL.Path, "/service/rest/v1/security/roles"):
+		f.restRoles(w, r)
+	case strings.HasPrefix(r.URL.Path, "/service/rest/v1/security/users"):
+		f.restUsers(w, r)
+	case r.Method == http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		f.mu.Lock()
+		f.settings[r.URL.Path] = string(body)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeNexus) rest(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/service/rest/v1/repositories"), "/")
+	switch r.Method {
+	case http.MethodGet:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if len(parts) == 4 {
+			rep, ok := f.repos[parts[3]]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(rep)
+			return
+		}
+		list := []map[string]string{}
+		for _, rep := range f.repos {
+			list = append(list, map[string]string{"name": rep.Name, "format": rep.Format, "type": rep.Type})
+		}
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost, http.MethodPut:
+		rep := Repository{}
+		json.NewDecoder(r.Body).Decode(&rep)
+		if len(parts) < 3 || parts[1] != "maven" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rep.Format, rep.Type = formatMaven, parts[2]
+		f.save(rep)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.repos, parts[1])
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeNexus) restRoles(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/service/rest/v1/security/roles"), "/")
+	role := Role{}
+	json.NewDecoder(r.Body).Decode(&role)
+	switch r.Method {
+	case http.MethodGet:
+		existing, ok := f.roles[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(existing)
+	case http.MethodPost:
+		f.roles[role.ID] = role
+	case http.MethodPut:
+		if _, ok := f.roles[id]; !ok || role.ID != id {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.roles[id] = role
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeNexus) restUsers(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/service/rest/v1/security/users"), "/")
+	switch {
+	case r.Method == http.MethodGet:
+		list := []User{}
+		for id, u := range f.users {
+			if strings.HasPrefix(id, r.URL.Query().Get("userId")) {
+				list = append(list, u)
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodPost:
+		u := User{}
+		json.NewDecoder(r.Body).Decode(&u)
+		f.passwords[u.UserID] = u.Password
+		u.Password = ""
+		u.Source = "default"
+		f.users[u.UserID] = u
+	case strings.HasSuffix(path, "/change-password"):
+		id := strings.TrimSuffix(path, "/change-password")
+		body, _ := ioutil.ReadAll(r.Body)
+		f.passwords[id] = string(body)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		u := User{}
+		json.NewDecoder(r.Body).Decode(&u)
+		if _, ok := f.users[path]; !ok || u.Source != "default" || u.Password != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.users[path] = u
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeNexus) extDirect(w http.ResponseWriter, r *http.Request) {
+	obj := struct {
+		Action string            `json:"action"`
+		Method string            `json:"method"`
+		Data   []json.RawMessage `json:"data"`
+	}{}
+	body, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(body, &obj)
+	f.mu.Lock()
+	key := obj.Action + "." + obj.Method
+	f.requests[key] = append(f.requests[key], string(body))
+	action, ok := f.actions[key]
+	if !ok {
+		action, ok = f.actions[obj.Action]
+	}
+	f.mu.Unlock()
+
+	result := map[string]interface{}{"success": true}
+	switch {
+	case ok:
+		data, err := action(obj.Method, obj.Data)
+		if err != nil {
+			result = map[string]interface{}{"success": false, "message": err.Error()}
+		} else if data != nil {
+			result["data"] = data
+		}
+	case obj.Action == "coreui_Role":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if obj.Method == "read" {
+			list := []Role{}
+			for _, role := range f.roles {
+				list = append(list, role)
+			}
+			result["data"] = list
+			break
+		}
+		role := Role{}
+		json.Unmarshal(obj.Data[0], &role)
+		if obj.Method == "update" && role.Version != f.roles[role.ID].Version {
+			result = map[string]interface{}{"success": false, "message": "invalid version"}
+			break
+		}
+		role.Version += "1"
+		f.roles[role.ID] = role
+	case obj.Action == "coreui_User":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if obj.Method == "read" {
+			list := []extDirectUser{}
+			for _, u := range f.users {
+				list = append(list, toExtDirectUser(u))
+			}
+			result["data"] = list
+			break
+		}
+		u := extDirectUser{}
+		json.Unmarshal(obj.Data[0], &u)
+		if obj.Method == "update" && u.Version != f.users[u.UserID].Version {
+			result = map[string]interface{}{"success": false, "message": "invalid version"}
+			break
+		}
+		if obj.Method == "create" {
+			f.passwords[u.UserID] = u.Password
+		}
+		f.users[u.UserID] = User{UserID: u.UserID, FirstName: u.FirstName, LastName: u.LastName,
+			EmailAddress: u.Email, Status: u.Status, Roles: u.Roles, Version: u.Version + "1"}
+	case strings.HasSuffix(obj.Action, "Settings") && obj.Method == "update":
+		f.mu.Lock()
+		f.settings[obj.Action] = string(obj.Data[0])
+		f.mu.Unlock()
+	case obj.Method == "read":
+		f.mu.Lock()
+		list := []DataConfig{}
+		for _, rep := range f.repos {
+			d := dataConfig(rep)
+			d.Format, d.Type = rep.Format, rep.Type
+			list = append(list, d)
+		}
+		f.mu.Unlock()
+		result["data"] = list
+	case obj.Method == "create", obj.Method == "update":
+		d := DataConfig{}
+		json.Unmarshal(obj.Data[0], &d)
+		rep := repositoryFromData(d)
+		parts := strings.SplitN(d.Recipe, "-", 2)
+		rep.Format, rep.Type = parts[0], parts[1]
+		f.save(rep)
+	case obj.Method == "remove":
+		name := ""
+		json.Unmarshal(obj.Data[0], &name)
+		f.mu.Lock()
+		delete(f.repos, name)
+		f.mu.Unlock()
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"tid": 1, "type": "rpc", "result": result})
+}
+
+func (f *fakeNexus) save(rep Repository) {
+	if rep.Group == nil {
+		// slow repositories to detect groups created before their members
+		time.Sleep(20 * time.Millisecond)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if rep.Group != nil {
+		for _, m := range rep.Group.MemberNames {
+			if _, ok := f.repos[m]; !ok {
+				f.errors = append(f.errors, rep.Name+" before "+m)
+			}
+		}
+	}
+	f.repos[rep.Name] = rep
+}
+
+// assertPayload compares a request body with the Nexus payload in testdata,
+// the transaction id of the ExtDirect requests is ignored
+func assertPayload(t *testing.T, fixture, body string) {
+	t.Helper()
+	recorded, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatalf("reading fixture %v", err)
+	}
+	var got, want interface{}
+	if err = json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("%v: invalid body %v %v", fixture, body, err)
+	}
+	json.Unmarshal(recorded, &want)
+	for _, v := range []interface{}{got, want} {
+		if m, ok := v.(map[string]interface{}); ok {
+			delete(m, "tid")
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%v: payload got: %v want: %s", fixture, body, recorded)
+	}
+}
+
+func TestRestSupported(t *testing.T) {
+	tests := map[string]bool{
+		"Nexus/3.12.0-01 (OSS)": false,
+		"Nexus/3.19.1-01 (OSS)": false,
+		"Nexus/3.20.0-04 (OSS)": true,
+		"Nexus/3.38.1-01 (PRO)": true,
+		"Nexus/4.0.0":           true,
+		"Nexus/2.14.5-02":       false,
+		"":                      true,
+	}
+	for server, want := range tests {
+		if got := restSupported(server); got != want {
+			t.Errorf("%q got: %v want: %v", server, got, want)
+		}
+	}
+}
+
+func TestNexusClient(t *testing.T) {
+	for _, server := range []string{"Nexus/3.21.1-01 (OSS)", "Nexus/3.12.0-01 (OSS)"} {
+		nexus := newFakeNexus(server)
+		srv := httptest.NewServer(nexus)
+		client, err := newNexusClient("admin", "admin123", srv.URL)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", server, err)
+		}
+		if client.extDirect == restSupported(server) {
+			t.Errorf("%v: extDirect got: %v", server, client.extDirect)
+		}
+
+		rep := hostedRepository(ArtifactoryHosted{Name: "releases", VersionPolicy: "RELEASE", LayoutPolicy: "STRICT"})
+		if err = client.createRepository(rep); err != nil {
+			t.Errorf("%v: unexpected error %v", server, err)
+		}
+		rep.Maven.LayoutPolicy = "PERMISSIVE"
+		if err = client.updateRepository(rep); err != nil {
+			t.Errorf("%v: unexpected error %v", server, err)
+		}
+		got, err := client.getRepository(formatMaven, typeHosted, "releases")
+		if err != nil || got.Maven == nil || got.Maven.LayoutPolicy != "PERMISSIVE" {
+			t.Errorf("%v: repository must be updated got: %+v %v", server, got, err)
+		}
+		names, err := client.repositories()
+		if err != nil || !names["releases"] {
+			t.Errorf("%v: repositories got: %v %v", server, names, err)
+		}
+		if err = client.deleteRepository("releases"); err != nil || len(nexus.repos) != 0 {
+			t.Errorf("%v: repository must be deleted got: %v %v", server, nexus.repos, err)
+		}
+		if _, err = client.getRepository(formatMaven, typeHosted, "releases"); err == nil {
+			t.Errorf("%v: must return an error with a missing repository", server)
+		}
+		srv.Close()
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// ClientConfig represents the generated client files in the report
+type ClientConfig struct {
+	MavenURL      string `json:"mavenUrl,omitempty"`
+	MavenSettings string `json:"mavenSettings,omitempty"`
+	NpmRegistry   string `json:"npmRegistry,omitempty"`
+	Npmrc         string `json:"npmrc,omitempty"`
+}
+
+// mavenSettings represents a maven settings.xml
+type mavenSettings struct {
+	XMLName xml.Name      `xml:"settings"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	Servers []mavenServer `xml:"servers>server"`
+	Mirrors []mavenMirror `xml:"mirrors>mirror"`
+}
+
+type mavenServer struct {
+	ID       string `xml:"id"`
+	Username string `xml:"username"`
+	Password string `xml:"password"`
+}
+
+type mavenMirror struct {
+	ID       string `xml:"id"`
+	MirrorOf string `xml:"mirrorOf"`
+	URL      string `xml:"url"`
+}
+
+// validateClientConfig checks that the groups are repositories of the config
+// or existing in nexus with the right format and that the user is in the config
+// with a password or is a new user with a generated password, existingUsers
+// contains the users in nexus
+func validateClientConfig(c ArtifactoryConfig, existing, existingUsers map[string]bool) error {
+	cc := c.ClientConfig
+	if cc == nil {
+		return nil
+	}
+	if cc.URL != "" {
+		if u, err := url.Parse(cc.URL); err != nil || u.Host == "" {
+			return fmt.Errorf("client config has an invalid url %v", cc.URL)
+		}
+	}
+	if cc.MavenSettingsFile != "" && cc.MavenGroup == "" {
+		return fmt.Errorf("client config requires mavenGroup to write %v", cc.MavenSettingsFile)
+	}
+	if cc.NpmrcFile != "" && cc.NpmGroup == "" {
+		return fmt.Errorf("client config requires npmGroup to write %v", cc.NpmrcFile)
+	}
+	formats := repositoryFormats(c)
+	groups := []struct{ name, format string }{{cc.MavenGroup, formatMaven}, {cc.NpmGroup, "npm"}}
+	for _, g := range groups {
+		group, format := g.name, g.format
+		if group == "" {
+			continue
+		}
+		f, ok := formats[group]
+		if !ok && existing != nil && !existing[group] {
+			return fmt.Errorf("client config has an unknown repository %v", group)
+		}
+		if ok && f != format {
+			return fmt.Errorf("client config repository %v is not %v", group, format)
+		}
+	}
+	if cc.Username != "" {
+		for _, u := range c.Users {
+			if u.Username != cc.Username {
+				continue
+			}
+			if u.Password == "" && u.NewPassword == "" && existingUsers[u.Username] {
+				return fmt.Errorf("client config user %v already exists and doesn't have a password in the config", u.Username)
+			}
+			return nil
+		}
+		return fmt.Errorf("client config has an unknown user %v", cc.Username)
+	}
+	return nil
+}
+
+// clientCredentials returns the credentials of the client config user, the
+// password generated for the user or the password of the config, the password
+// is empty when the user already existed without a password in the config
+func clientCredentials(c ArtifactoryConfig, creds []UserCredentials) (string, string) {
+	user := c.ClientConfig.Username
+	for _, cr := range creds {
+		if cr.Username == user && cr.Password != "" {
+			return user, cr.Password
+		}
+	}
+	for _, u := range c.Users {
+		if u.Username == user {
+			if u.NewPassword != "" {
+				return user, u.NewPassword
+			}
+			return user, u.Password
+		}
+	}
+	return user, ""
+}
+
+// clientConfig generates the maven settings.xml and .npmrc of the config
+func clientConfig(c ArtifactoryConfig, host string, creds []UserCredentials) (ClientConfig, error) {
+	cc := c.ClientConfig
+	base := strings.TrimSuffix(host, "/")
+	if cc.URL != "" {
+		base = strings.TrimSuffix(cc.URL, "/")
+	}
+	user, pass := clientCredentials(c, creds)
+	if user != "" && pass == "" {
+		return ClientConfig{}, fmt.Errorf("client config user %v without password", user)
+	}
+	result := ClientConfig{}
+
+	if cc.MavenGroup != "" {
+		result.MavenURL = base + "/repository/" + cc.MavenGroup + "/"
+		settings, err := mavenSettingsXML(c, result.MavenURL, user, pass)
+		if err != nil {
+			return result, err
+		}
+		result.MavenSettings = settings
+	}
+
+	if cc.NpmGroup != "" {
+		result.NpmRegistry = base + "/repository/" + cc.NpmGroup + "/"
+		npmrc := "registry=" + result.NpmRegistry + "\n"
+		if user != "" {
+			// the auth is scoped to the registry without the scheme
+			scope := strings.TrimPrefix(strings.TrimPrefix(result.NpmRegistry, "https:"), "http:")
+			auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+			npmrc += "always-auth=true\n" + scope + ":_auth=" + auth + "\n"
+		}
+		result.Npmrc = npmrc
+	}
+	return result, nil
+}
+
+// mavenSettingsXML returns a settings.xml mirroring all the repositories with
+// the group and a server for the group and each maven hosted repository
+func mavenSettingsXML(c ArtifactoryConfig, mirrorURL, user, pass string) (string, error) {
+	if user == "" {
+		user, pass = "${env.NEXUS_USERNAME}", "${env.NEXUS_PASSWORD}"
+	}
+	group := c.ClientConfig.MavenGroup
+	settings := mavenSettings{
+		Xmlns:   "http://maven.apache.org/SETTINGS/1.0.0",
+		Servers: []mavenServer{{ID: group, Username: user, Password: pass}},
+		Mirrors: []mavenMirror{{ID: group, MirrorOf: "*", URL: mirrorURL}},
+	}
+	for _, h := range c.Hosteds {
+		if repositoryFormat(h.Format) == formatMaven && h.Name != group {
+			settings.Servers = append(settings.Servers, mavenServer{ID: h.Name, Username: user, Password: pass})
+		}
+	}
+	data, err := xml.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(data) + "\n", nil
+}
+
+// writeClientConfig generates the client files of the config and adds them to the report
+func writeClientConfig(c ArtifactoryConfig, host string, report *Report) error {
+	cc := c.ClientConfig
+	if cc == nil {
+		return nil
+	}
+	result, err := clientConfig(c, host, report.Users)
+	if err != nil {
+		return err
+	}
+	files := []struct{ path, content string }{
+		{cc.MavenSettingsFile, result.MavenSettings},
+		{cc.NpmrcFile, result.Npmrc},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		// the files contain credentials
+		err = ioutil.WriteFile(f.path, []byte(f.content), 0600)
+		if err != nil {
+			return err
+		}
+		log.Printf("client config written to %v", f.path)
+	}
+	if cc.Report {
+		report.ClientConfig = &result
+	}
+	return nil
+}
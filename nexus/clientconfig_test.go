@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func clientTestConfig() ArtifactoryConfig {
+	return ArtifactoryConfig{
+		Groups: []ArtifactoryGroup{
+			{Name: "public", Members: []string{"releases", "central"}},
+			{Name: "npm-all", Format: "npm", Members: []string{"npm-registry"}},
+		},
+		Hosteds: []ArtifactoryHosted{{Name: "releases"}, {Name: "npm-hosted", Format: "npm"}},
+		Proxies: []ArtifactoryProxy{{Name: "central"}, {Name: "npm-registry", Format: "npm"}},
+		Users:   []ArtifactoryUser{{Username: "ci"}},
+		ClientConfig: &ArtifactoryClientConfig{
+			URL:        "https://nexus.example.org/",
+			MavenGroup: "public",
+			NpmGroup:   "npm-all",
+		},
+	}
+}
+
+func TestValidateClientConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cc *ArtifactoryClientConfig)
+		valid  bool
+	}{
+		{"valid", func(cc *ArtifactoryClientConfig) {}, true},
+		{"existing group", func(cc *ArtifactoryClientConfig) { cc.MavenGroup = "maven-public" }, true},
+		{"unknown group", func(cc *ArtifactoryClientConfig) { cc.MavenGroup = "missing" }, false},
+		{"npm maven group", func(cc *ArtifactoryClientConfig) { cc.MavenGroup = "npm-all" }, false},
+		{"maven npm group", func(cc *ArtifactoryClientConfig) { cc.NpmGroup = "public" }, false},
+		{"invalid url", func(cc *ArtifactoryClientConfig) { cc.URL = "nexus" }, false},
+		{"file without group", func(cc *ArtifactoryClientConfig) { cc.NpmGroup, cc.NpmrcFile = "", ".npmrc" }, false},
+		{"user", func(cc *ArtifactoryClientConfig) { cc.Username = "ci" }, true},
+		{"unknown user", func(cc *ArtifactoryClientConfig) { cc.Username = "other" }, false},
+		// the password of an existing user without password in the config is unknown
+		{"existing user", func(cc *ArtifactoryClientConfig) { cc.Username = "jdoe" }, false},
+	}
+	existing := map[string]bool{"maven-public": true}
+	existingUsers := map[string]bool{"ci": true, "jdoe": true}
+	for _, test := range tests {
+		c := clientTestConfig()
+		c.Users[0].Password = "secret"
+		c.Users = append(c.Users, ArtifactoryUser{Username: "jdoe"})
+		test.modify(c.ClientConfig)
+		err := validateClientConfig(c, existing, existingUsers)
+		if (err == nil) != test.valid {
+			t.Errorf("%v valid: %v got error: %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	c := clientTestConfig()
+	result, err := clientConfig(c, "http://nexus:8081", nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.MavenURL != "https://nexus.example.org/repository/public/" {
+		t.Errorf("maven url got: %v", result.MavenURL)
+	}
+	settings := mavenSettings{}
+	if err = xml.Unmarshal([]byte(result.MavenSettings), &settings); err != nil {
+		t.Fatalf("invalid settings.xml %v", err)
+	}
+	// the group and the maven hosted repositories
+	if len(settings.Servers) != 2 || settings.Servers[1].ID != "releases" {
+		t.Errorf("unexpected servers %v", settings.Servers)
+	}
+	if settings.Servers[0].Username != "${env.NEXUS_USERNAME}" {
+		t.Errorf("username got: %v want the environment variable", settings.Servers[0].Username)
+	}
+	if len(settings.Mirrors) != 1 || settings.Mirrors[0].MirrorOf != "*" || settings.Mirrors[0].URL != result.MavenURL {
+		t.Errorf("unexpected mirrors %v", settings.Mirrors)
+	}
+	if result.Npmrc != "registry=https://nexus.example.org/repository/npm-all/\n" {
+		t.Errorf("npmrc got: %q", result.Npmrc)
+	}
+
+	// the generated password of the user is used
+	c.ClientConfig.URL = ""
+	c.ClientConfig.Username = "ci"
+	result, err = clientConfig(c, "http://nexus:8081", []UserCredentials{{Username: "ci", Password: "secret"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(result.MavenSettings, "<password>secret</password>") {
+		t.Errorf("settings without the password %v", result.MavenSettings)
+	}
+	want := "registry=http://nexus:8081/repository/npm-all/\nalways-auth=true\n//nexus:8081/repository/npm-all/:_auth=Y2k6c2VjcmV0\n"
+	if result.Npmrc != want {
+		t.Errorf("npmrc got: %q want: %q", result.Npmrc, want)
+	}
+
+	// an existing user without password in the config
+	if _, err = clientConfig(c, "http://nexus:8081", []UserCredentials{{Username: "ci"}}); err == nil {
+		t.Error("must return an error without the password of the user")
+	}
+}
+
+func TestWriteClientConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := clientTestConfig()
+	c.ClientConfig.MavenSettingsFile = filepath.Join(dir, "settings.xml")
+	c.ClientConfig.NpmrcFile = filepath.Join(dir, ".npmrc")
+
+	report := Report{}
+	if err = writeClientConfig(c, "http://nexus:8081", &report); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if report.ClientConfig != nil {
+		t.Error("client config in the report without report")
+	}
+	for _, f := range []string{c.ClientConfig.MavenSettingsFile, c.ClientConfig.NpmrcFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%v mode got: %v want: 0600", f, info.Mode().Perm())
+		}
+	}
+
+	c.ClientConfig.Report = true
+	if err = writeClientConfig(c, "http://nexus:8081", &report); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if report.ClientConfig == nil || report.ClientConfig.MavenSettings == "" {
+		t.Errorf("client config not in the report %v", report.ClientConfig)
+	}
+}
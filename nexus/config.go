@@ -3,142 +3,433 @@ package main
 // ArtifactoryConfig represents the global configuration to apply in nexus
 // this configuration comes from the k8s secret
 type ArtifactoryConfig struct {
-	Groups  []ArtifactoryGroup  `json:"groups,omitempty"`
-	Hosteds []ArtifactoryHosted `json:"hosteds,omitempty"`
-	Proxies []ArtifactoryProxy  `json:"proxies,omitempty"`
+	// Security is applied before the repositories
+	Security *ArtifactorySecurity `json:"security,omitempty"`
+	Groups   []ArtifactoryGroup   `json:"groups,omitempty"`
+	Hosteds  []ArtifactoryHosted  `json:"hosteds,omitempty"`
+	Proxies  []ArtifactoryProxy   `json:"proxies,omitempty"`
+	// HTTPSettings are applied before the repositories
+	HTTPSettings *ArtifactoryHTTPSettings `json:"http_settings,omitempty"`
+	// TrustStore certificates are added before the repositories
+	TrustStore *ArtifactoryTrustStore `json:"trust_store,omitempty"`
+	// CleanupPolicies are created before the repositories
+	CleanupPolicies []ArtifactoryCleanupPolicy `json:"cleanup_policies,omitempty"`
+	// Uploads are uploaded after the repositories
+	Uploads []ArtifactoryUpload `json:"uploads,omitempty"`
+	// Tasks are created after the repositories
+	Tasks []ArtifactoryTask `json:"tasks,omitempty"`
+	// Roles are created after the repositories
+	Roles []ArtifactoryRole `json:"roles,omitempty"`
+	// Users are created after the roles
+	Users []ArtifactoryUser `json:"users,omitempty"`
+	// ClientConfig is generated after the users
+	ClientConfig *ArtifactoryClientConfig `json:"client_config,omitempty"`
+	// Verify probes the proxies and groups at the end of the job
+	Verify *ArtifactoryVerify `json:"verify,omitempty"`
+}
+
+// ArtifactoryVerify represents the probes of the proxy and group repositories
+type ArtifactoryVerify struct {
+	// Timeout of each probe in seconds, 30 by default
+	Timeout int `json:"timeout"`
+	// Probes are the paths requested by repository name or format, a maven2 probe
+	// can be a groupId:artifactId coordinate to request its maven-metadata.xml,
+	// maven2 repositories use junit:junit by default
+	Probes map[string]string `json:"probes"`
+	// Required fails the job when a repository doesn't return content
+	Required bool `json:"required"`
+}
+
+// ArtifactoryClientConfig represents the maven and npm client files to generate
+type ArtifactoryClientConfig struct {
+	// URL is the nexus URL used by the clients, NEXUS_HOST by default
+	URL string `json:"url"`
+	// MavenGroup is the repository mirrored in the settings.xml
+	MavenGroup string `json:"mavenGroup"`
+	// MavenSettingsFile is the settings.xml to write, empty doesn't write it
+	MavenSettingsFile string `json:"mavenSettingsFile"`
+	// NpmGroup is the registry of the .npmrc
+	NpmGroup string `json:"npmGroup"`
+	// NpmrcFile is the .npmrc to write, empty doesn't write it
+	NpmrcFile string `json:"npmrcFile"`
+	// Username is a user of the config whose credentials are written
+	// in the files, empty uses the NEXUS_USERNAME and NEXUS_PASSWORD
+	// environment variables of the client in the settings.xml
+	Username string `json:"username"`
+	// Report adds the generated files to the output file
+	Report bool `json:"report"`
+}
+
+// ArtifactorySecurity represents the security settings of the server
+type ArtifactorySecurity struct {
+	// Realms are the active realms in order e.g. NexusAuthenticatingRealm
+	// NexusAuthorizingRealm NpmToken DockerToken, empty keeps the active realms
+	Realms []string `json:"realms,omitempty"`
+	// Anonymous is the anonymous access, nil keeps the anonymous access
+	Anonymous *ArtifactoryAnonymous `json:"anonymous,omitempty"`
+}
+
+// ArtifactoryAnonymous represents the anonymous access settings
+type ArtifactoryAnonymous struct {
+	Enabled bool `json:"enabled"`
+	// UserID is the user of the anonymous requests, anonymous by default
+	UserID string `json:"userId"`
+	// RealmName is the realm of the user, NexusAuthorizingRealm by default
+	RealmName string `json:"realmName"`
+}
+
+// ArtifactoryHTTPSettings represents the settings of the outbound requests of the server
+type ArtifactoryHTTPSettings struct {
+	// HTTPProxy is the proxy of the http requests
+	HTTPProxy *ArtifactoryOutboundProxy `json:"httpProxy"`
+	// HTTPSProxy is the proxy of the https requests, it requires HTTPProxy
+	HTTPSProxy *ArtifactoryOutboundProxy `json:"httpsProxy"`
+	// NonProxyHosts are the hosts requested without proxy e.g. *.corp.local
+	NonProxyHosts []string `json:"nonProxyHosts"`
+	// Timeout are the seconds to wait for the remotes, the nexus default when empty
+	Timeout int `json:"timeout"`
+	// Retries are the connection attempts to the remotes, the nexus default when empty
+	Retries         *int   `json:"retries"`
+	UserAgentSuffix string `json:"userAgentSuffix"`
+}
+
+// ArtifactoryOutboundProxy represents a proxy of the outbound requests
+type ArtifactoryOutboundProxy struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// Authentication supports the username and ntlm types
+	Authentication *ArtifactoryAuth `json:"authentication"`
+}
+
+// ArtifactoryTrustStore represents the certificates to add to the nexus trust store
+type ArtifactoryTrustStore struct {
+	// Certificates are PEM encoded certificates
+	Certificates []string `json:"certificates"`
+	// Endpoints are host:port addresses, the certificate of each endpoint is added
+	Endpoints []string `json:"endpoints"`
+}
+
+// ArtifactoryCleanupPolicy represents a cleanup policy, the components matching
+// all the criteria are removed by the cleanup task
+type ArtifactoryCleanupPolicy struct {
+	Name  string `json:"name"`
+	Notes string `json:"notes"`
+	// Format of the repositories, ALL_FORMATS by default
+	Format string `json:"format"`
+	// LastDownloaded are the days since the component was downloaded
+	LastDownloaded int `json:"lastDownloaded"`
+	// LastBlobUpdated are the days since the component was published
+	LastBlobUpdated int `json:"lastBlobUpdated"`
+	// ReleaseType the options are: RELEASES PRERELEASES
+	ReleaseType string `json:"releaseType"`
+	// AssetRegex matches the path of the assets
+	AssetRegex string `json:"assetRegex"`
+}
+
+// ArtifactoryUpload represents local artifacts to upload into a hosted repository
+type ArtifactoryUpload struct {
+	// Repository is the name of the hosted repository
+	Repository string `json:"repository"`
+	// Format the options are: maven2 npm raw, by default the format of
+	// the repository in the config or maven2
+	Format string `json:"format"`
+	// Path is a file or a directory, a maven2 directory has the layout of
+	// a maven repository, npm files are package tarballs
+	Path string `json:"path"`
+	// Directory is the directory of the raw assets in the repository, / by default
+	Directory string `json:"directory"`
+}
+
+// ArtifactoryTask represents a scheduled task
+type ArtifactoryTask struct {
+	Name string `json:"name"`
+	// TypeID is the type of the task e.g. blobstore.compact repository.cleanup
+	// repository.maven.rebuild-metadata repository.rebuild-index
+	TypeID string `json:"typeId"`
+	// Schedule the options are: manual cron daily weekly, manual by default
+	Schedule string `json:"schedule"`
+	// Cron is the expression of the cron schedule e.g. 0 0 1 * * ?
+	Cron string `json:"cron"`
+	// Time is the UTC time of the daily and weekly schedules, 00:00 by default
+	Time string `json:"time"`
+	// Days of the weekly schedule e.g. sunday saturday
+	Days []string `json:"days"`
+	// Disabled creates the task without running it
+	Disabled   bool   `json:"disabled"`
+	AlertEmail string `json:"alertEmail"`
+	// Properties are the settings of the type e.g. blobstoreName repositoryName
+	Properties map[string]string `json:"properties"`
 }
 
 // ArtifactoryUser represents a user in the server
 type ArtifactoryUser struct {
-	Username    string `json:"username"`
-	Password    string `json:"password"`
+	Username string `json:"username"`
+	// Password is used to create the user, a password is generated when
+	// Password and NewPassword are empty
+	Password string `json:"password"`
+	// NewPassword changes the password of an existing user
 	NewPassword string `json:"newpassword"`
+	FirstName   string `json:"firstName"`
+	LastName    string `json:"lastName"`
+	Email       string `json:"email"`
+	// Status the options are: active disabled locked, active by default
+	Status string `json:"status"`
+	// Roles are the ids of the roles assigned to the user
+	Roles []string `json:"roles"`
+}
+
+// ArtifactoryRole represents a role with its privileges
+type ArtifactoryRole struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Privileges are the names of existing privileges
+	Privileges []string `json:"privileges"`
+	// RepositoryPrivileges generates repository view privileges
+	RepositoryPrivileges []RepositoryPrivilege `json:"repositoryPrivileges"`
+	// Roles are the ids of the nested roles
+	Roles []string `json:"roles"`
+}
+
+// RepositoryPrivilege represents the view privileges of the actions on repositories,
+// each repository and action generates the nx-repository-view-{format}-{repo}-{action} privilege
+type RepositoryPrivilege struct {
+	// Repositories are the repository names, * means all the repositories of the format
+	Repositories []string `json:"repositories"`
+	// Format of the repositories, by default the format of the repository in
+	// the config or maven2, * means all the formats
+	Format string `json:"format"`
+	// Actions the options are: browse read edit add delete *
+	Actions []string `json:"actions"`
 }
 
 // ArtifactoryGroup represents a group repository
 type ArtifactoryGroup struct {
-	Name    string   `json:"name"`
-	Members []string `json:"members"`
+	Name string `json:"name"`
+	// Format the options are: maven2 npm raw, maven2 by default
+	Format  string   `json:"format,omitempty"`
+	Members []string `json:"members,omitempty"`
 }
 
 // ArtifactoryHosted represents a hosted repository
 type ArtifactoryHosted struct {
 	Name string `json:"name"`
+	// Format the options are: maven2 npm raw, maven2 by default
+	Format string `json:"format,omitempty"`
 	// VersionPolicy the options are: RELEASE SNAPSHOT MIXED
-	VersionPolicy string `json:"versionPolicy"`
+	VersionPolicy string `json:"versionPolicy,omitempty"`
 	// LayoutPolicy the options are: STRICT PERMISSIVE
-	LayoutPolicy string `json:"layoutPolicy"`
+	LayoutPolicy string `json:"layoutPolicy,omitempty"`
+	// CleanupPolicies are the names of the cleanup policies of the repository
+	CleanupPolicies []string `json:"cleanupPolicies,omitempty"`
+	// WritePolicy the options are: ALLOW ALLOW_ONCE DENY, ALLOW by default
+	WritePolicy string `json:"writePolicy,omitempty"`
+	// StrictContentTypeValidation validates the content type of the uploads, true by default
+	StrictContentTypeValidation *bool `json:"strictContentTypeValidation,omitempty"`
+	// ContentDisposition for maven repositories the options are: INLINE ATTACHMENT
+	ContentDisposition string `json:"contentDisposition,omitempty"`
 }
 
 // ArtifactoryProxy represents a proxy repository
 type ArtifactoryProxy struct {
 	Name string `json:"name"`
+	// Format the options are: maven2 npm raw, maven2 by default
+	Format string `json:"format,omitempty"`
 	// VersionPolicy the options are: RELEASE SNAPSHOT MIXED
-	VersionPolicy string `json:"versionPolicy"`
+	VersionPolicy string `json:"versionPolicy,omitempty"`
 	// LayoutPolicy the options are: STRICT PERMISSIVE
-	LayoutPolicy string `json:"layoutPolicy"`
+	LayoutPolicy string `json:"layoutPolicy,omitempty"`
+	// CleanupPolicies are the names of the cleanup policies of the repository
+	CleanupPolicies []string `json:"cleanupPolicies,omitempty"`
 	// RemoteURL is remote url to proxied
-	RemoteURL string `json:"remoteUrl"`
+	RemoteURL string `json:"remoteUrl,omitempty"`
 	// RequiredAuth set to true if the proxy required authentication
-	RequiredAuth bool `json:"requiredAuth"`
+	RequiredAuth bool `json:"requiredAuth,omitempty"`
 	// Authentication is required if RequiredAuth is set to true
-	Authentication *ArtifactoryAuth `json:"authentication"`
+	Authentication *ArtifactoryAuth `json:"authentication,omitempty"`
+	// ContentMaxAge are the minutes to cache the artifacts, -1 by default (forever)
+	ContentMaxAge *int `json:"contentMaxAge,omitempty"`
+	// MetadataMaxAge are the minutes to cache the metadata, 1440 by default
+	MetadataMaxAge *int `json:"metadataMaxAge,omitempty"`
+	// NegativeCache caches the missing artifacts, true by default
+	NegativeCache *bool `json:"negativeCache,omitempty"`
+	// NegativeCacheTTL are the minutes to cache the missing artifacts, 1440 by default
+	NegativeCacheTTL *int `json:"negativeCacheTTL,omitempty"`
+	// AutoBlock blocks the proxy when the remote is unreachable, true by default
+	AutoBlock *bool `json:"autoBlock,omitempty"`
+	// Timeout are the seconds to wait for the remote, the nexus default when empty
+	Timeout int `json:"timeout,omitempty"`
+	// Retries are the connection attempts to the remote, the nexus default when empty
+	Retries *int `json:"retries,omitempty"`
+	// UserAgentSuffix is added to the user agent of the requests to the remote
+	UserAgentSuffix string `json:"userAgentSuffix,omitempty"`
+	// UseTrustStore uses the nexus trust store for the remote certificates
+	UseTrustStore bool `json:"useTrustStore,omitempty"`
+	// Headers are added to the requests to the remote
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // ArtifactoryAuth is the auth for artifactory proxy repository
 type ArtifactoryAuth struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	// Type the options are: username ntlm bearerToken, username by default
+	Type     string `json:"type,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// NtlmHost and NtlmDomain are required by the ntlm type
+	NtlmHost   string `json:"ntlmHost,omitempty"`
+	NtlmDomain string `json:"ntlmDomain,omitempty"`
+	// BearerToken is required by the bearerToken type
+	BearerToken string `json:"bearerToken,omitempty"`
 }
 
-func hostedDataConfig(h ArtifactoryHosted) DataConfig {
-	return DataConfig{
-		Name:   h.Name,
-		Online: true,
-		Recipe: "maven2-hosted",
-		Attributes: Attributes{
-			Maven: &Maven{
-				VersionPolicy: h.VersionPolicy,
-				LayoutPolicy:  h.LayoutPolicy,
-			},
-			Storage: Storage{
-				BlobStoreName:               "default",
-				StrictContentTypeValidation: true,
-				WritePolicy:                 "ALLOW",
-			},
-		},
+// repositoryFormat returns the format of a repository, maven2 by default
+func repositoryFormat(format string) string {
+	if format == "" {
+		return formatMaven
 	}
+	return format
 }
 
-func proxyDataConfig(p ArtifactoryProxy) DataConfig {
-	auth := &Authentication{}
-	if p.RequiredAuth {
-		auth.Type = "username"
-		auth.UserName = p.Authentication.Username
-		auth.Password = p.Authentication.Password
-		auth.NtlmDomain = ""
-		auth.NtlmHost = ""
-	} else {
-		// nil is ignored in the json
-		auth = nil
+// mavenAttributes returns the maven attributes only for maven repositories
+func mavenAttributes(format, versionPolicy, layoutPolicy string) *Maven {
+	if format != formatMaven {
+		return nil
+	}
+	return &Maven{
+		VersionPolicy: versionPolicy,
+		LayoutPolicy:  layoutPolicy,
 	}
-	return DataConfig{
-		Name:        p.Name,
-		Online:      true,
-		AuthEnabled: p.RequiredAuth,
-		Recipe:      "maven2-proxy",
-		Attributes: Attributes{
-			Maven: &Maven{
-				VersionPolicy: p.VersionPolicy,
-				LayoutPolicy:  p.LayoutPolicy,
-			},
-			Proxy: &Proxy{
-				RemoteURL:      p.RemoteURL,
-				ContentMaxAge:  -1,
-				MetadataMaxAge: 1440,
-			},
-			HTTPClient: &HTTPClient{
-				Blocked:        false,
-				AutoBlock:      true,
-				Authentication: auth,
-			},
-			Storage: Storage{
-				BlobStoreName:               "default",
-				StrictContentTypeValidation: true,
-			},
-			NegativeCache: &NegativeCache{
-				Enabled:    true,
-				TimeToLive: 1440,
-			},
+}
+
+// cleanupAttributes returns the cleanup attributes only when there are policies
+func cleanupAttributes(policies []string) *Cleanup {
+	if len(policies) == 0 {
+		return nil
+	}
+	return &Cleanup{PolicyNames: policies}
+}
+
+func hostedRepository(h ArtifactoryHosted) Repository {
+	format := repositoryFormat(h.Format)
+	rep := Repository{
+		Name:    h.Name,
+		Format:  format,
+		Type:    typeHosted,
+		Online:  true,
+		Maven:   mavenAttributes(format, h.VersionPolicy, h.LayoutPolicy),
+		Cleanup: cleanupAttributes(h.CleanupPolicies),
+		Storage: Storage{
+			BlobStoreName:               "default",
+			StrictContentTypeValidation: boolDefault(h.StrictContentTypeValidation, true),
+			WritePolicy:                 h.WritePolicy,
 		},
 	}
+	if rep.Storage.WritePolicy == "" {
+		rep.Storage.WritePolicy = "ALLOW"
+	}
+	if rep.Maven != nil {
+		rep.Maven.ContentDisposition = h.ContentDisposition
+	}
+	return rep
 }
 
-func groupDataConfig(g ArtifactoryGroup) DataConfig {
-	return DataConfig{
-		Name:   g.Name,
-		Online: true,
-		Recipe: "maven2-group",
-		Attributes: Attributes{
-			Storage: Storage{
-				BlobStoreName:               "default",
-				StrictContentTypeValidation: true,
-			},
-			Group: &Group{
-				MemberNames: g.Members,
-			},
+func proxyRepository(p ArtifactoryProxy) Repository {
+	format := repositoryFormat(p.Format)
+	return Repository{
+		Name:    p.Name,
+		Format:  format,
+		Type:    typeProxy,
+		Online:  true,
+		Maven:   mavenAttributes(format, p.VersionPolicy, p.LayoutPolicy),
+		Cleanup: cleanupAttributes(p.CleanupPolicies),
+		Proxy: &Proxy{
+			RemoteURL:      p.RemoteURL,
+			ContentMaxAge:  intDefault(p.ContentMaxAge, -1),
+			MetadataMaxAge: intDefault(p.MetadataMaxAge, 1440),
+		},
+		HTTPClient: &HTTPClient{
+			Blocked:        false,
+			AutoBlock:      boolDefault(p.AutoBlock, true),
+			Connection:     proxyConnection(p),
+			Authentication: proxyAuthentication(p),
+		},
+		Storage: Storage{
+			BlobStoreName:               "default",
+			StrictContentTypeValidation: true,
+		},
+		NegativeCache: &NegativeCache{
+			Enabled:    boolDefault(p.NegativeCache, true),
+			TimeToLive: intDefault(p.NegativeCacheTTL, 1440),
 		},
 	}
 }
 
-func nexusConfig(data DataConfig) NexusConfig {
-	return NexusConfig{
-		Action: "coreui_Repository",
-		Method: "create",
-		Data: []DataConfig{
-			data,
+// proxyAuthentication returns the authentication of the proxy,
+// nil when the proxy doesn't require authentication (nil is ignored in the json)
+func proxyAuthentication(p ArtifactoryProxy) *Authentication {
+	if !p.RequiredAuth || p.Authentication == nil {
+		return nil
+	}
+	a := p.Authentication
+	auth := &Authentication{Type: a.Type}
+	switch a.Type {
+	case "bearerToken":
+		auth.BearerToken = a.BearerToken
+	case "ntlm":
+		auth.UserName = a.Username
+		auth.Password = a.Password
+		auth.NtlmHost = a.NtlmHost
+		auth.NtlmDomain = a.NtlmDomain
+	default:
+		auth.Type = "username"
+		auth.UserName = a.Username
+		auth.Password = a.Password
+	}
+	return auth
+}
+
+// proxyConnection returns the connection settings only when they are set
+func proxyConnection(p ArtifactoryProxy) *Connection {
+	if p.Timeout == 0 && p.Retries == nil && p.UserAgentSuffix == "" && !p.UseTrustStore && len(p.Headers) == 0 {
+		return nil
+	}
+	return &Connection{
+		Timeout:         p.Timeout,
+		Retries:         p.Retries,
+		UserAgentSuffix: p.UserAgentSuffix,
+		UseTrustStore:   p.UseTrustStore,
+		Headers:         p.Headers,
+	}
+}
+
+// intDefault returns the value of v or def when v is nil
+func intDefault(v *int, def int) int {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// boolDefault returns the value of v or def when v is nil
+func boolDefault(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func groupRepository(g ArtifactoryGroup) Repository {
+	return Repository{
+		Name:   g.Name,
+		Format: repositoryFormat(g.Format),
+		Type:   typeGroup,
+		Online: true,
+		Storage: Storage{
+			BlobStoreName:               "default",
+			StrictContentTypeValidation: true,
+		},
+		Group: &Group{
+			MemberNames: g.Members,
 		},
-		Type: "rpc",
-		TID:  27,
 	}
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyRepositoryDefaults(t *testing.T) {
+	rep := proxyRepository(ArtifactoryProxy{Name: "central", RemoteURL: "https://repo1.maven.org/maven2/"})
+	if rep.Proxy.ContentMaxAge != -1 || rep.Proxy.MetadataMaxAge != 1440 {
+		t.Errorf("proxy max ages got: %+v", rep.Proxy)
+	}
+	if !rep.HTTPClient.AutoBlock || rep.HTTPClient.Connection != nil {
+		t.Errorf("http client got: %+v", rep.HTTPClient)
+	}
+	if !rep.NegativeCache.Enabled || rep.NegativeCache.TimeToLive != 1440 {
+		t.Errorf("negative cache got: %+v", rep.NegativeCache)
+	}
+}
+
+// createdPayload creates the repository in a fakeNexus and returns the request body
+func createdPayload(t *testing.T, rep Repository) string {
+	t.Helper()
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err = client.createRepository(rep); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	bodies := nexus.bodies("POST /service/rest/v1/repositories/maven/" + rep.Type)
+	if len(bodies) != 1 {
+		t.Fatalf("requests got: %v", bodies)
+	}
+	return bodies[0]
+}
+
+func TestProxyRepositoryTuning(t *testing.T) {
+	zero, ttl, retries, off := 0, 60, 3, false
+	rep := proxyRepository(ArtifactoryProxy{
+		Name:             "mirror",
+		VersionPolicy:    "RELEASE",
+		LayoutPolicy:     "PERMISSIVE",
+		RemoteURL:        "http://mirror.internal/maven2/",
+		ContentMaxAge:    &ttl,
+		MetadataMaxAge:   &zero,
+		NegativeCache:    &off,
+		NegativeCacheTTL: &ttl,
+		AutoBlock:        &off,
+		Timeout:          120,
+		Retries:          &retries,
+		UserAgentSuffix:  "init-nexus",
+		UseTrustStore:    true,
+	})
+	assertPayload(t, "rest/maven_proxy.json", createdPayload(t, rep))
+}
+
+func TestHostedRepositoryStorage(t *testing.T) {
+	rep := hostedRepository(ArtifactoryHosted{Name: "snapshots"})
+	if rep.Storage.WritePolicy != "ALLOW" || !rep.Storage.StrictContentTypeValidation || rep.Maven.ContentDisposition != "" {
+		t.Errorf("default storage got: %+v %+v", rep.Storage, rep.Maven)
+	}
+
+	off := false
+	rep = hostedRepository(ArtifactoryHosted{Name: "releases", VersionPolicy: "RELEASE", LayoutPolicy: "STRICT",
+		WritePolicy: "ALLOW_ONCE", ContentDisposition: "ATTACHMENT"})
+	assertPayload(t, "rest/maven_hosted.json", createdPayload(t, rep))
+	rep = hostedRepository(ArtifactoryHosted{Name: "files", Format: "raw", WritePolicy: "DENY", StrictContentTypeValidation: &off})
+	if rep.Storage.WritePolicy != "DENY" || rep.Storage.StrictContentTypeValidation || rep.Maven != nil {
+		t.Errorf("raw storage got: %+v", rep.Storage)
+	}
+}
+
+func TestProxyAuthentication(t *testing.T) {
+	tests := map[string]struct {
+		auth *ArtifactoryAuth
+		want string
+	}{
+		"username": {
+			auth: &ArtifactoryAuth{Username: "user", Password: "pass", NtlmHost: "ignored"},
+			want: `{"type":"username","username":"user","password":"pass","ntlmHost":"","ntlmDomain":""}`,
+		},
+		"ntlm": {
+			auth: &ArtifactoryAuth{Type: "ntlm", Username: "user", Password: "pass", NtlmHost: "host", NtlmDomain: "CORP"},
+			want: `{"type":"ntlm","username":"user","password":"pass","ntlmHost":"host","ntlmDomain":"CORP"}`,
+		},
+		"bearer token": {
+			auth: &ArtifactoryAuth{Type: "bearerToken", BearerToken: "token", Username: "ignored"},
+			want: `{"type":"bearerToken","username":"","password":"","ntlmHost":"","ntlmDomain":"","bearerToken":"token"}`,
+		},
+	}
+	for name, tt := range tests {
+		p := ArtifactoryProxy{Name: "a", RequiredAuth: true, Authentication: tt.auth}
+		body, _ := json.Marshal(proxyAuthentication(p))
+		if string(body) != tt.want {
+			t.Errorf("%v: got: %s want: %v", name, body, tt.want)
+		}
+	}
+
+	if proxyAuthentication(ArtifactoryProxy{RequiredAuth: true}) != nil {
+		t.Error("missing authentication must not panic")
+	}
+	if proxyAuthentication(ArtifactoryProxy{Authentication: tests["ntlm"].auth}) != nil {
+		t.Error("authentication must be ignored without requiredAuth")
+	}
+
+	rep := proxyRepository(ArtifactoryProxy{Name: "a", Headers: map[string]string{"X-Api-Key": "key"}})
+	if rep.HTTPClient.Connection == nil || rep.HTTPClient.Connection.Headers["X-Api-Key"] != "key" {
+		t.Errorf("headers got: %+v", rep.HTTPClient.Connection)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// redacted replaces the passwords and tokens of the exported proxies
+const redacted = "${REDACTED}"
+
+// exportRepositories returns all the repositories in nexus with their configuration
+func (c *nexusClient) exportRepositories() ([]Repository, error) {
+	repos := []Repository{}
+	if c.extDirect {
+		resp, err := c.call("coreui_Repository", "read")
+		if err != nil {
+			return nil, err
+		}
+		data := []DataConfig{}
+		err = json.Unmarshal(resp.Result.Data, &data)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range data {
+			repos = append(repos, repositoryFromData(d))
+		}
+	} else {
+		list := []struct {
+			Name   string `json:"name"`
+			Format string `json:"format"`
+			Type   string `json:"type"`
+		}{}
+		err := c.do(http.MethodGet, "/v1/repositories", nil, &list)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range list {
+			r, err := c.getRepository(l.Format, l.Type, l.Name)
+			if err != nil {
+				return nil, err
+			}
+			repos = append(repos, r)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+	return repos, nil
+}
+
+// exportConfig returns the config of the repositories, the settings with the default
+// value are omitted and the credentials of the proxies are replaced with redacted
+func exportConfig(repos []Repository) ArtifactoryConfig {
+	c := ArtifactoryConfig{}
+	for _, r := range repos {
+		switch r.Type {
+		case typeHosted:
+			c.Hosteds = append(c.Hosteds, exportHosted(r))
+		case typeProxy:
+			p := exportProxy(r)
+			if p.RequiredAuth {
+				log.Printf("proxy %v credentials replaced with %v", p.Name, redacted)
+			}
+			c.Proxies = append(c.Proxies, p)
+		case typeGroup:
+			g := ArtifactoryGroup{Name: r.Name, Format: exportFormat(r.Format), Members: []string{}}
+			if r.Group != nil {
+				g.Members = r.Group.MemberNames
+			}
+			c.Groups = append(c.Groups, g)
+		default:
+			log.Printf("repository %v has an unknown type %v", r.Name, r.Type)
+		}
+	}
+	return c
+}
+
+// exportFormat returns the format of the config, empty for the default maven2
+func exportFormat(format string) string {
+	if format == formatMaven {
+		return ""
+	}
+	return format
+}
+
+func exportHosted(r Repository) ArtifactoryHosted {
+	h := ArtifactoryHosted{
+		Name:   r.Name,
+		Format: exportFormat(r.Format),
+	}
+	if r.Maven != nil {
+		h.VersionPolicy = r.Maven.VersionPolicy
+		h.LayoutPolicy = r.Maven.LayoutPolicy
+		h.ContentDisposition = r.Maven.ContentDisposition
+	}
+	if r.Cleanup != nil {
+		h.CleanupPolicies = r.Cleanup.PolicyNames
+	}
+	if r.Storage.WritePolicy != "ALLOW" {
+		h.WritePolicy = r.Storage.WritePolicy
+	}
+	h.StrictContentTypeValidation = boolChanged(r.Storage.StrictContentTypeValidation, true)
+	return h
+}
+
+func exportProxy(r Repository) ArtifactoryProxy {
+	p := ArtifactoryProxy{
+		Name:   r.Name,
+		Format: exportFormat(r.Format),
+	}
+	if r.Maven != nil {
+		p.VersionPolicy = r.Maven.VersionPolicy
+		p.LayoutPolicy = r.Maven.LayoutPolicy
+	}
+	if r.Cleanup != nil {
+		p.CleanupPolicies = r.Cleanup.PolicyNames
+	}
+	if r.Proxy != nil {
+		p.RemoteURL = r.Proxy.RemoteURL
+		p.ContentMaxAge = intChanged(r.Proxy.ContentMaxAge, -1)
+		p.MetadataMaxAge = intChanged(r.Proxy.MetadataMaxAge, 1440)
+	}
+	if r.NegativeCache != nil {
+		p.NegativeCache = boolChanged(r.NegativeCache.Enabled, true)
+		p.NegativeCacheTTL = intChanged(r.NegativeCache.TimeToLive, 1440)
+	}
+	if r.HTTPClient == nil {
+		return p
+	}
+	p.AutoBlock = boolChanged(r.HTTPClient.AutoBlock, true)
+	if conn := r.HTTPClient.Connection; conn != nil {
+		p.Timeout = conn.Timeout
+		p.Retries = conn.Retries
+		p.UserAgentSuffix = conn.UserAgentSuffix
+		p.UseTrustStore = conn.UseTrustStore
+		p.Headers = conn.Headers
+	}
+	if a := r.HTTPClient.Authentication; a != nil {
+		// the passwords and tokens are not exported
+		p.RequiredAuth = true
+		p.Authentication = &ArtifactoryAuth{
+			Type:       a.Type,
+			Username:   a.UserName,
+			NtlmHost:   a.NtlmHost,
+			NtlmDomain: a.NtlmDomain,
+		}
+		if a.Type == "bearerToken" {
+			p.Authentication.BearerToken = redacted
+		} else {
+			p.Authentication.Password = redacted
+		}
+	}
+	return p
+}
+
+// intChanged returns a pointer to v only when it is not the default value
+func intChanged(v, def int) *int {
+	if v == def {
+		return nil
+	}
+	return &v
+}
+
+// boolChanged returns a pointer to v only when it is not the default value
+func boolChanged(v, def bool) *bool {
+	if v == def {
+		return nil
+	}
+	return &v
+}
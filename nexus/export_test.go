@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// exportTestConfig returns the repositories exported by the tests
+func exportTestConfig() ArtifactoryConfig {
+	retries := 3
+	ttl := 60
+	disabled := false
+	return ArtifactoryConfig{
+		Hosteds: []ArtifactoryHosted{
+			{Name: "releases", VersionPolicy: "RELEASE", LayoutPolicy: "STRICT", WritePolicy: "ALLOW_ONCE",
+				ContentDisposition: "ATTACHMENT", CleanupPolicies: []string{"old"}},
+			{Name: "snapshots", VersionPolicy: "SNAPSHOT", LayoutPolicy: "STRICT", StrictContentTypeValidation: &disabled},
+		},
+		Proxies: []ArtifactoryProxy{
+			{Name: "central", VersionPolicy: "RELEASE", LayoutPolicy: "PERMISSIVE", RemoteURL: "https://repo1.maven.org/maven2/",
+				NegativeCacheTTL: &ttl, AutoBlock: &disabled, Timeout: 60, Retries: &retries, UseTrustStore: true},
+			{Name: "private", VersionPolicy: "RELEASE", LayoutPolicy: "PERMISSIVE", RemoteURL: "https://private.example.org",
+				RequiredAuth: true, Authentication: &ArtifactoryAuth{Type: "ntlm", Username: "user", Password: "secret",
+					NtlmHost: "host", NtlmDomain: "CORP"}},
+		},
+		Groups: []ArtifactoryGroup{{Name: "public", Members: []string{"releases", "central"}}},
+	}
+}
+
+// exportFrom applies the config in a fakeNexus of the server and exports it
+func exportFrom(t *testing.T, server string, c ArtifactoryConfig) ArtifactoryConfig {
+	t.Helper()
+	nexus := newFakeNexus(server)
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("%v: unexpected error %v", server, err)
+	}
+	runTasks(repositoryTasks(client, c, nil), 2)
+	repos, err := client.exportRepositories()
+	if err != nil {
+		t.Fatalf("%v: unexpected error %v", server, err)
+	}
+	return exportConfig(repos)
+}
+
+func TestExportConfig(t *testing.T) {
+	c := exportTestConfig()
+
+	for _, server := range []string{"Nexus/3.21.1-01 (OSS)", "Nexus/3.12.0-01 (OSS)"} {
+		got := exportFrom(t, server, c)
+
+		// the credentials are redacted
+		want := c
+		want.Proxies = append([]ArtifactoryProxy{}, c.Proxies...)
+		auth := *c.Proxies[1].Authentication
+		auth.Password = redacted
+		want.Proxies[1].Authentication = &auth
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: export got: %+v want: %+v", server, got, want)
+		}
+	}
+}
+
+func TestApplyExportedConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := exportTestConfig()
+	file := filepath.Join(dir, "nexus.yaml")
+	if err = cms.EncodeToFile(file, exportFrom(t, "Nexus/3.21.1-01 (OSS)", c), 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	exported := ArtifactoryConfig{}
+	if err = cms.DecodeFromFile(file, &exported); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = validateProxies(exported)
+	if err == nil || !strings.Contains(err.Error(), "proxy private") || !strings.Contains(err.Error(), redacted) {
+		t.Errorf("the redacted password must be rejected got: %v", err)
+	}
+
+	// the exported file applies again once the credential is set
+	exported.Proxies[1].Authentication.Password = "secret"
+	if err = validateProxies(exported); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err = validateGroups(exported, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got, want := exportFrom(t, "Nexus/3.21.1-01 (OSS)", exported), exportFrom(t, "Nexus/3.21.1-01 (OSS)", c); !reflect.DeepEqual(got, want) {
+		t.Errorf("applied export got: %+v want: %+v", got, want)
+	}
+}
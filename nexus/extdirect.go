@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StatusError is returned when nexus responds with an unexpected status code
+type StatusError struct {
+	Method string
+	URL    string
+	Code   int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error %v %v code: %v message: %v", e.Method, e.URL, e.Code, e.Body)
+}
+
+// ExtDirectError is returned when an ExtDirect method fails,
+// Exception is true when nexus responds with an exception
+type ExtDirectError struct {
+	Action    string
+	Method    string
+	TID       int
+	Exception bool
+	Message   string
+	// Errors contains the validation errors by field name
+	Errors map[string]string
+}
+
+func (e *ExtDirectError) Error() string {
+	msg := fmt.Sprintf("error %v.%v (tid %v)", e.Action, e.Method, e.TID)
+	if e.Exception {
+		msg += " exception"
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if len(e.Errors) > 0 {
+		fields := []string{}
+		for f := range e.Errors {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for i, f := range fields {
+			fields[i] = f + ": " + e.Errors[f]
+		}
+		msg += " [" + strings.Join(fields, ", ") + "]"
+	}
+	return msg
+}
+
+// decodeResponse decodes the ExtDirect body of the request obj, the body can be
+// a single response or a batch, in a batch the response with the tid of obj is returned.
+// A response without success returns an *ExtDirectError
+func decodeResponse(body []byte, obj NexusConfig) (Response, error) {
+	responses := []Response{}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		err := json.Unmarshal(body, &responses)
+		if err != nil {
+			return Response{}, fmt.Errorf("decoding ExtDirect batch response %v: %v", err, string(body))
+		}
+	} else {
+		r := Response{}
+		err := json.Unmarshal(body, &r)
+		if err != nil {
+			return Response{}, fmt.Errorf("decoding ExtDirect response %v: %v", err, string(body))
+		}
+		responses = append(responses, r)
+	}
+
+	for _, r := range responses {
+		if len(responses) > 1 && r.TID != obj.TID {
+			continue
+		}
+		return r, responseError(r)
+	}
+	return Response{}, fmt.Errorf("ExtDirect response without tid %v: %v", obj.TID, string(body))
+}
+
+// responseError returns the error of a response or nil when it was successful
+func responseError(r Response) error {
+	e := &ExtDirectError{Action: r.Action, Method: r.Method, TID: r.TID}
+	switch {
+	case r.Type == "exception":
+		e.Exception = true
+		e.Message = r.Message
+	case r.Result == nil:
+		e.Message = "response without result"
+	case !r.Result.Success:
+		e.Message = r.Result.Message
+		e.Errors = r.Result.Errors
+		if e.Message == "" && len(e.Errors) == 0 {
+			e.Message = "unsuccessful response"
+		}
+	default:
+		return nil
+	}
+	return e
+}
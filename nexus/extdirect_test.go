@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	obj := NexusConfig{Action: "coreui_Repository", Method: "create", TID: 27}
+	tests := map[string]struct {
+		exception bool
+		message   string
+		errors    int
+	}{
+		"success.json":     {},
+		"batch.json":       {},
+		"validation.json":  {errors: 1, message: "name: Name is already used"},
+		"denied.json":      {message: "Access denied (authentication required)"},
+		"exception.json":   {exception: true, message: "Could not find method"},
+		"batch_error.json": {errors: 2, message: "[attributes.group.memberNames: Repository does not exist: missing, name:"},
+		"no_result.json":   {message: "response without result"},
+	}
+	for name, tt := range tests {
+		body, err := ioutil.ReadFile(filepath.Join("testdata", "extdirect", name))
+		if err != nil {
+			t.Fatalf("reading fixture %v", err)
+		}
+		resp, err := decodeResponse(body, obj)
+		if tt.message == "" {
+			if err != nil || resp.TID != 27 || len(resp.Result.Data) == 0 {
+				t.Errorf("%v: unexpected response %+v error %v", name, resp, err)
+			}
+			continue
+		}
+
+		e, ok := err.(*ExtDirectError)
+		if !ok {
+			t.Errorf("%v: error must be *ExtDirectError got: %#v", name, err)
+			continue
+		}
+		if e.Exception != tt.exception || len(e.Errors) != tt.errors || e.TID != 27 {
+			t.Errorf("%v: unexpected error %#v", name, e)
+		}
+		if !strings.Contains(e.Error(), tt.message) {
+			t.Errorf("%v: error got: %v want: %v", name, e.Error(), tt.message)
+		}
+	}
+
+	for _, body := range []string{"", "<html></html>", `[{"tid":1,"result":{"success":true}},{"tid":2,"result":{"success":true}}]`} {
+		if _, err := decodeResponse([]byte(body), obj); err == nil {
+			t.Errorf("%q: must return an error", body)
+		}
+	}
+}
+
+func TestNexusCallStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Service Unavailable"))
+	}))
+	defer srv.Close()
+
+	_, err := nexusCall("admin", "admin123", srv.URL, NexusConfig{Action: "coreui_Repository", Method: "read"})
+	e, ok := err.(*StatusError)
+	if !ok {
+		t.Fatalf("error must be *StatusError got: %#v", err)
+	}
+	if e.Code != http.StatusServiceUnavailable || !strings.Contains(e.Error(), "message: Service Unavailable") {
+		t.Errorf("unexpected error %v", e)
+	}
+}
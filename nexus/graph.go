@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// validateGroups checks that the repository names are unique and that the group
+// members are repositories of the config or existing in nexus, without cycles.
+// When existing is nil the members out of the config are not verified
+func validateGroups(c ArtifactoryConfig, existing map[string]bool) error {
+	names := map[string]bool{}
+	for _, n := range repositoryNames(c) {
+		if names[n] {
+			return fmt.Errorf("repository %v is defined more than once", n)
+		}
+		names[n] = true
+	}
+
+	for _, g := range c.Groups {
+		for _, m := range g.Members {
+			if !names[m] && existing != nil && !existing[m] {
+				return fmt.Errorf("group %v has an unknown member %v", g.Name, m)
+			}
+		}
+	}
+
+	deps := map[string][]string{}
+	order := []string{}
+	for _, g := range c.Groups {
+		order = append(order, g.Name)
+		deps[g.Name] = g.Members
+	}
+	if cycle := findCycle(order, deps); cycle != nil {
+		return fmt.Errorf("groups with a cycle %v", cycle)
+	}
+	return nil
+}
+
+// findCycle returns the first cycle of the graph visiting the nodes in order,
+// deps contains the nodes each node depends on, nodes out of deps are ignored
+func findCycle(order []string, deps map[string][]string) []string {
+	// depth first search, visiting marks the nodes in the current path
+	visiting := map[string]bool{}
+	visited := map[string]bool{}
+	var visit func(name string, path []string) []string
+	visit = func(name string, path []string) []string {
+		if visiting[name] {
+			return append(path, name)
+		}
+		if visited[name] {
+			return nil
+		}
+		visiting[name] = true
+		for _, d := range deps[name] {
+			if _, ok := deps[d]; ok {
+				if cycle := visit(d, append(path, name)); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		return nil
+	}
+	for _, n := range order {
+		if cycle := visit(n, nil); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// repositoryNames returns the names of all the repositories in the config
+func repositoryNames(c ArtifactoryConfig) []string {
+	names := []string{}
+	for _, h := range c.Hosteds {
+		names = append(names, h.Name)
+	}
+	for _, p := range c.Proxies {
+		names = append(names, p.Name)
+	}
+	for _, g := range c.Groups {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// repositoryFormats returns the format of each repository in the config
+func repositoryFormats(c ArtifactoryConfig) map[string]string {
+	formats := map[string]string{}
+	for _, h := range c.Hosteds {
+		formats[h.Name] = repositoryFormat(h.Format)
+	}
+	for _, p := range c.Proxies {
+		formats[p.Name] = repositoryFormat(p.Format)
+	}
+	for _, g := range c.Groups {
+		formats[g.Name] = repositoryFormat(g.Format)
+	}
+	return formats
+}
+
+// repositoryTasks returns the tasks to create the repositories of the config,
+// the task of a group depends on the tasks of its members defined in the config.
+// Repositories already in nexus are updated with the config
+func repositoryTasks(client *nexusClient, c ArtifactoryConfig, existing map[string]bool) []*cms.Task {
+	tasks := []*cms.Task{}
+	byName := map[string]*cms.Task{}
+	add := func(r Repository) *cms.Task {
+		t := cms.NewTask(r.Type+" "+r.Name, func() error {
+			if existing[r.Name] {
+				err := client.updateRepository(r)
+				if err != nil {
+					return err
+				}
+				log.Printf("%v repository %v updated", r.Type, r.Name)
+				return nil
+			}
+			err := client.createRepository(r)
+			if err != nil {
+				return err
+			}
+			log.Printf("%v repository %v created", r.Type, r.Name)
+			return nil
+		})
+		tasks = append(tasks, t)
+		byName[r.Name] = t
+		return t
+	}
+
+	for _, h := range c.Hosteds {
+		add(hostedRepository(h))
+	}
+	for _, p := range c.Proxies {
+		add(proxyRepository(p))
+	}
+	groups := []*cms.Task{}
+	for _, g := range c.Groups {
+		groups = append(groups, add(groupRepository(g)))
+	}
+	for i, g := range c.Groups {
+		for _, m := range g.Members {
+			if dep, ok := byName[m]; ok {
+				groups[i].Deps = append(groups[i].Deps, dep)
+			}
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+func TestValidateGroups(t *testing.T) {
+	hosteds := []ArtifactoryHosted{{Name: "releases"}}
+	tests := map[string]struct {
+		config   ArtifactoryConfig
+		existing map[string]bool
+		err      string
+	}{
+		"valid": {
+			config: ArtifactoryConfig{Hosteds: hosteds, Groups: []ArtifactoryGroup{
+				{Name: "public", Members: []string{"internal", "maven-central"}},
+				{Name: "internal", Members: []string{"releases"}},
+			}},
+			existing: map[string]bool{"maven-central": true},
+		},
+		"not verified": {
+			config: ArtifactoryConfig{Groups: []ArtifactoryGroup{{Name: "public", Members: []string{"maven-central"}}}},
+		},
+		"unknown member": {
+			config:   ArtifactoryConfig{Groups: []ArtifactoryGroup{{Name: "public", Members: []string{"missing"}}}},
+			existing: map[string]bool{},
+			err:      "unknown member missing",
+		},
+		"duplicated": {
+			config: ArtifactoryConfig{Hosteds: hosteds, Groups: []ArtifactoryGroup{{Name: "releases"}}},
+			err:    "more than once",
+		},
+		"cycle": {
+			config: ArtifactoryConfig{Groups: []ArtifactoryGroup{
+				{Name: "a", Members: []string{"b"}},
+				{Name: "b", Members: []string{"c"}},
+				{Name: "c", Members: []string{"a"}},
+			}},
+			err: "cycle [a b c a]",
+		},
+	}
+	for name, tt := range tests {
+		err := validateGroups(tt.config, tt.existing)
+		if tt.err == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%v: error got: %v want: %v", name, err, tt.err)
+		}
+	}
+}
+
+func TestRepositoryTasks(t *testing.T) {
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	data := ArtifactoryConfig{
+		Hosteds: []ArtifactoryHosted{{Name: "releases"}, {Name: "snapshots"}},
+		Proxies: []ArtifactoryProxy{{Name: "central"}, {Name: "existing"}},
+		Groups: []ArtifactoryGroup{
+			{Name: "public", Members: []string{"internal", "central"}},
+			{Name: "internal", Members: []string{"releases", "snapshots", "existing"}},
+		},
+	}
+	existing := map[string]bool{"existing": true}
+	nexus.repos["existing"] = Repository{Name: "existing"}
+	tasks := repositoryTasks(client, data, existing)
+	if len(tasks) != 6 {
+		t.Fatalf("tasks got: %d want: 6", len(tasks))
+	}
+	cms.RunTasks(tasks, 4)
+
+	for _, task := range tasks {
+		if task.Err != nil {
+			t.Errorf("unexpected error in %v: %v", task.Name, task.Err)
+		}
+	}
+	if nexus.repos["existing"].Proxy == nil {
+		t.Error("existing repository must be updated")
+	}
+	if len(nexus.errors) > 0 {
+		t.Errorf("groups created before their members: %v", nexus.errors)
+	}
+	if len(nexus.repos) != 6 {
+		t.Errorf("repositories created got: %v", nexus.repos)
+	}
+}
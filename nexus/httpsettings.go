@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// HTTPSettings represents the outbound settings in the coreui_HttpSettings ExtDirect methods,
+// nexus doesn't have a REST API for the outbound settings
+type HTTPSettings struct {
+	UserAgentSuffix     string   `json:"userAgentSuffix,omitempty"`
+	Timeout             int      `json:"timeout,omitempty"`
+	Retries             *int     `json:"retries,omitempty"`
+	HTTPEnabled         bool     `json:"httpEnabled"`
+	HTTPHost            string   `json:"httpHost,omitempty"`
+	HTTPPort            int      `json:"httpPort,omitempty"`
+	HTTPAuthEnabled     bool     `json:"httpAuthEnabled"`
+	HTTPAuthUsername    string   `json:"httpAuthUsername,omitempty"`
+	HTTPAuthPassword    string   `json:"httpAuthPassword,omitempty"`
+	HTTPAuthNtlmHost    string   `json:"httpAuthNtlmHost,omitempty"`
+	HTTPAuthNtlmDomain  string   `json:"httpAuthNtlmDomain,omitempty"`
+	HTTPSEnabled        bool     `json:"httpsEnabled"`
+	HTTPSHost           string   `json:"httpsHost,omitempty"`
+	HTTPSPort           int      `json:"httpsPort,omitempty"`
+	HTTPSAuthEnabled    bool     `json:"httpsAuthEnabled"`
+	HTTPSAuthUsername   string   `json:"httpsAuthUsername,omitempty"`
+	HTTPSAuthPassword   string   `json:"httpsAuthPassword,omitempty"`
+	HTTPSAuthNtlmHost   string   `json:"httpsAuthNtlmHost,omitempty"`
+	HTTPSAuthNtlmDomain string   `json:"httpsAuthNtlmDomain,omitempty"`
+	NonProxyHosts       []string `json:"nonProxyHosts"`
+}
+
+// validateHTTPSettings checks the outbound proxies of the http settings
+func validateHTTPSettings(c ArtifactoryConfig) error {
+	h := c.HTTPSettings
+	if h == nil {
+		return nil
+	}
+	if h.HTTPSProxy != nil && h.HTTPProxy == nil {
+		return fmt.Errorf("http_settings httpsProxy requires httpProxy")
+	}
+	if h.Timeout < 0 || h.Timeout > 3600 {
+		return fmt.Errorf("http_settings timeout must be between 1 and 3600 seconds")
+	}
+	if h.Retries != nil && (*h.Retries < 0 || *h.Retries > 10) {
+		return fmt.Errorf("http_settings retries must be between 0 and 10")
+	}
+	for name, p := range map[string]*ArtifactoryOutboundProxy{"httpProxy": h.HTTPProxy, "httpsProxy": h.HTTPSProxy} {
+		if p == nil {
+			continue
+		}
+		if p.Host == "" || p.Port < 1 || p.Port > 65535 {
+			return fmt.Errorf("http_settings %v requires host and port", name)
+		}
+		if a := p.Authentication; a != nil {
+			if a.Type != "" && a.Type != "username" && a.Type != "ntlm" {
+				return fmt.Errorf("http_settings %v has an unknown authentication type %v", name, a.Type)
+			}
+			if a.Username == "" || a.Password == "" {
+				return fmt.Errorf("http_settings %v authentication requires username and password", name)
+			}
+			if a.Type == "ntlm" && (a.NtlmHost == "" || a.NtlmDomain == "") {
+				return fmt.Errorf("http_settings %v ntlm authentication requires ntlmHost and ntlmDomain", name)
+			}
+		}
+	}
+	return nil
+}
+
+// httpSettings returns the ExtDirect outbound settings of the config
+func httpSettings(h ArtifactoryHTTPSettings) HTTPSettings {
+	s := HTTPSettings{
+		UserAgentSuffix: h.UserAgentSuffix,
+		Timeout:         h.Timeout,
+		Retries:         h.Retries,
+		NonProxyHosts:   append([]string{}, h.NonProxyHosts...),
+	}
+	if p := h.HTTPProxy; p != nil {
+		s.HTTPEnabled, s.HTTPHost, s.HTTPPort = true, p.Host, p.Port
+		if a := p.Authentication; a != nil {
+			s.HTTPAuthEnabled, s.HTTPAuthUsername, s.HTTPAuthPassword = true, a.Username, a.Password
+			s.HTTPAuthNtlmHost, s.HTTPAuthNtlmDomain = a.NtlmHost, a.NtlmDomain
+		}
+	}
+	if p := h.HTTPSProxy; p != nil {
+		s.HTTPSEnabled, s.HTTPSHost, s.HTTPSPort = true, p.Host, p.Port
+		if a := p.Authentication; a != nil {
+			s.HTTPSAuthEnabled, s.HTTPSAuthUsername, s.HTTPSAuthPassword = true, a.Username, a.Password
+			s.HTTPSAuthNtlmHost, s.HTTPSAuthNtlmDomain = a.NtlmHost, a.NtlmDomain
+		}
+	}
+	return s
+}
+
+// applyHTTPSettings replaces the outbound settings of nexus
+func (c *nexusClient) applyHTTPSettings(h *ArtifactoryHTTPSettings) error {
+	if h == nil {
+		return nil
+	}
+	_, err := c.call("coreui_HttpSettings", "update", httpSettings(*h))
+	if err != nil {
+		return err
+	}
+	log.Printf("http settings updated, http proxy: %v https proxy: %v", h.HTTPProxy != nil, h.HTTPSProxy != nil)
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApplyHTTPSettings(t *testing.T) {
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	retries := 3
+	settings := &ArtifactoryHTTPSettings{
+		HTTPProxy: &ArtifactoryOutboundProxy{Host: "proxy.corp.local", Port: 3128},
+		HTTPSProxy: &ArtifactoryOutboundProxy{Host: "proxy.corp.local", Port: 3129, Authentication: &ArtifactoryAuth{
+			Type: "ntlm", Username: "user", Password: "pass", NtlmHost: "nexus", NtlmDomain: "CORP",
+		}},
+		NonProxyHosts: []string{"*.corp.local", "localhost"},
+		Timeout:       60,
+		Retries:       &retries,
+	}
+	if err = validateHTTPSettings(ArtifactoryConfig{HTTPSettings: settings}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err = client.applyHTTPSettings(settings); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	bodies := nexus.bodies("coreui_HttpSettings.update")
+	if len(bodies) != 1 {
+		t.Fatalf("requests got: %v", bodies)
+	}
+	assertPayload(t, "extdirect/http_settings_update.json", bodies[0])
+}
+
+func TestValidateHTTPSettings(t *testing.T) {
+	proxy := &ArtifactoryOutboundProxy{Host: "proxy", Port: 3128}
+	tests := map[string]*ArtifactoryHTTPSettings{
+		"https without http": {HTTPSProxy: proxy},
+		"without port":       {HTTPProxy: &ArtifactoryOutboundProxy{Host: "proxy"}},
+		"timeout":            {Timeout: 4000},
+		"auth type":          {HTTPProxy: &ArtifactoryOutboundProxy{Host: "proxy", Port: 1, Authentication: &ArtifactoryAuth{Type: "bearerToken", Username: "u", Password: "p"}}},
+		"auth password":      {HTTPProxy: &ArtifactoryOutboundProxy{Host: "proxy", Port: 1, Authentication: &ArtifactoryAuth{Username: "u"}}},
+		"ntlm domain":        {HTTPProxy: &ArtifactoryOutboundProxy{Host: "proxy", Port: 1, Authentication: &ArtifactoryAuth{Type: "ntlm", Username: "u", Password: "p", NtlmHost: "h"}}},
+	}
+	for name, h := range tests {
+		if validateHTTPSettings(ArtifactoryConfig{HTTPSettings: h}) == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+}
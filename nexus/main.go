@@ -3,10 +3,10 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	cms "github.com/xumak-grid/init-containers/pkg/commons"
@@ -20,6 +20,14 @@ const (
 	nexusConfigFileEnv = "NEXUS_CONFIG_FILE"
 	// nexusTimeout represents the maximun timeout to wait for the nexus
 	nexusTimeout = "NEXUS_TIMEOUT"
+	// nexusConcurrencyEnv is the number of repositories created at the same time
+	nexusConcurrencyEnv = "NEXUS_CONCURRENCY"
+	// nexusModeEnv is apply (default) to configure nexus or export to write its configuration
+	nexusModeEnv = "NEXUS_MODE"
+	// nexusExportFileEnv is the file written by the export mode, yaml with the .yaml or .yml extension
+	nexusExportFileEnv = "NEXUS_EXPORT_FILE"
+	// nexusOutputFileEnv is the file to write the report with the generated credentials
+	nexusOutputFileEnv = "NEXUS_OUTPUT_FILE"
 )
 
 // NexusConfig represents a valid configuration to create a resource in nexus,
@@ -27,20 +35,43 @@ const (
 type NexusConfig struct {
 	Action string `json:"action"`
 	Method string `json:"method"`
-	// Data receives a slice of DataConfig but to make a POST request only one
-	// object must be added.
-	Data []DataConfig `json:"data"`
-	Type string       `json:"type"`
-	TID  int          `json:"tid"`
+	// Data receives a slice with the arguments of the method, to create
+	// a repository only one DataConfig must be added.
+	Data interface{} `json:"data"`
+	Type string      `json:"type"`
+	TID  int         `json:"tid"`
 }
 
 // Response represents the response of the request in the Nexus server
 type Response struct {
+	TID    int    `json:"tid"`
+	Action string `json:"action"`
+	Method string `json:"method"`
+	// Type is rpc or exception when the method failed with an exception
+	Type string `json:"type"`
+	// Message is the exception message
+	Message string `json:"message,omitempty"`
 	// Result is a result of the POST, when an error is present
 	// the Suceess is equals to false
-	Result *struct {
-		Success bool `json:"success"`
-	} `json:"result,omitempty"`
+	Result *Result `json:"result,omitempty"`
+}
+
+// Result represents the result of an ExtDirect method
+type Result struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+	// Errors contains the validation errors by field name
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Report represents the result of the job written in the output file
+type Report struct {
+	Users []UserCredentials `json:"users"`
+	// Verify contains the results of the probes when verify is in the config
+	Verify []VerifyResult `json:"verify,omitempty"`
+	// ClientConfig contains the generated client files when client_config.report is true
+	ClientConfig *ClientConfig `json:"client_config,omitempty"`
 }
 
 // DataConfig represents a configuration data for each POST in nexus
@@ -56,6 +87,22 @@ type DataConfig struct {
 	Recipe              string     `json:"recipe"`
 }
 
+// Repository represents a repository in the nexus REST API,
+// Format and Type are part of the path and not of the body
+type Repository struct {
+	Name          string         `json:"name"`
+	Format        string         `json:"-"`
+	Type          string         `json:"-"`
+	Online        bool           `json:"online"`
+	Storage       Storage        `json:"storage"`
+	Cleanup       *Cleanup       `json:"cleanup,omitempty"`
+	Group         *Group         `json:"group,omitempty"`
+	Maven         *Maven         `json:"maven,omitempty"`
+	Proxy         *Proxy         `json:"proxy,omitempty"`
+	HTTPClient    *HTTPClient    `json:"httpClient,omitempty"`
+	NegativeCache *NegativeCache `json:"negativeCache,omitempty"`
+}
+
 // Attributes represents attributes from the data config.
 type Attributes struct {
 	Storage       Storage        `json:"storage,omitempty"`
@@ -64,6 +111,8 @@ type Attributes struct {
 	Proxy         *Proxy         `json:"proxy,omitempty"`
 	HTTPClient    *HTTPClient    `json:"httpclient,omitempty"`
 	NegativeCache *NegativeCache `json:"negativeCache,omitempty"`
+	// Cleanup uses the ExtDirect names, the REST API uses Repository.Cleanup
+	Cleanup *ExtDirectCleanup `json:"cleanup,omitempty"`
 }
 
 // Storage represents storage options as part of attributes.
@@ -73,6 +122,16 @@ type Storage struct {
 	WritePolicy                 string `json:"writePolicy,omitempty"`
 }
 
+// Cleanup represents the cleanup policies of a repository.
+type Cleanup struct {
+	PolicyNames []string `json:"policyNames"`
+}
+
+// ExtDirectCleanup represents the cleanup policies of a repository in ExtDirect.
+type ExtDirectCleanup struct {
+	PolicyName []string `json:"policyName"`
+}
+
 // Group represents a group of repositories.
 type Group struct {
 	MemberNames []string `json:"memberNames"`
@@ -82,6 +141,8 @@ type Group struct {
 type Maven struct {
 	VersionPolicy string `json:"versionPolicy"`
 	LayoutPolicy  string `json:"layoutPolicy"`
+	// ContentDisposition is only used by hosted repositories
+	ContentDisposition string `json:"contentDisposition,omitempty"`
 }
 
 // Proxy represents a proxy configuration for a repository.
@@ -95,9 +156,20 @@ type Proxy struct {
 type HTTPClient struct {
 	Blocked        bool            `json:"blocked"`
 	AutoBlock      bool            `json:"autoBlock"`
+	Connection     *Connection     `json:"connection,omitempty"`
 	Authentication *Authentication `json:"authentication"`
 }
 
+// Connection represents the connection settings of the http configuration.
+type Connection struct {
+	Retries         *int   `json:"retries,omitempty"`
+	UserAgentSuffix string `json:"userAgentSuffix,omitempty"`
+	Timeout         int    `json:"timeout,omitempty"`
+	UseTrustStore   bool   `json:"useTrustStore,omitempty"`
+	// Headers are the custom headers of the requests to the remote
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
 // NegativeCache contains cache specific settings.
 type NegativeCache struct {
 	Enabled    bool `json:"enabled"`
@@ -106,11 +178,12 @@ type NegativeCache struct {
 
 // Authentication groups auth settings for a repository.
 type Authentication struct {
-	Type       string `json:"type"`
-	UserName   string `json:"username"`
-	Password   string `json:"password"`
-	NtlmHost   string `json:"ntlmHost"`
-	NtlmDomain string `json:"ntlmDomain"`
+	Type        string `json:"type"`
+	UserName    string `json:"username"`
+	Password    string `json:"password"`
+	NtlmHost    string `json:"ntlmHost"`
+	NtlmDomain  string `json:"ntlmDomain"`
+	BearerToken string `json:"bearerToken,omitempty"`
 }
 
 // nexusReady checks if nexus is ready to receive the POST commands
@@ -137,18 +210,19 @@ func nexusReady(user, pass, host string) bool {
 	return false
 }
 
-// nexusPost creates a new resource making a POST request to nexus
-func nexusPost(user, pass, host string, obj NexusConfig) error {
-
+// nexusCall makes a POST request to the ExtDirect endpoint and returns the response
+// of the method, a failed method returns an *ExtDirectError
+func nexusCall(user, pass, host string, obj NexusConfig) (Response, error) {
+	nexusResponse := Response{}
 	host = host + "/service/extdirect"
 	jsonData, err := json.Marshal(obj)
 	if err != nil {
-		return err
+		return nexusResponse, err
 	}
 
 	req, err := http.NewRequest(http.MethodPost, host, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return err
+		return nexusResponse, err
 	}
 
 	req.SetBasicAuth(user, pass)
@@ -156,26 +230,17 @@ func nexusPost(user, pass, host string, obj NexusConfig) error {
 	client := cms.GetClient(5)
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nexusResponse, err
 	}
 	defer resp.Body.Close()
 
 	body, _ := ioutil.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("error creating resource code: %v message: %v", resp.StatusCode, body)
+		return nexusResponse, &StatusError{Method: http.MethodPost, URL: host, Code: resp.StatusCode, Body: string(body)}
 	}
 
-	nexusResponse := Response{}
-	err = json.Unmarshal(body, &nexusResponse)
-	if err != nil {
-		return err
-	}
-	if !nexusResponse.Result.Success {
-		return fmt.Errorf("error: %v \nPOST: %v on data: %v", string(body), host, string(jsonData))
-	}
-
-	return nil
+	return decodeResponse(body, obj)
 }
 
 func main() {
@@ -185,12 +250,24 @@ func main() {
 	user := cms.GetEnv(nexusUserEnv, "admin")
 	pass := cms.GetEnv(nexusPassEnv, "admin123")
 	host := cms.GetEnv(nexusHostEnv, "http://localhost:8081")
+	outputFile := cms.GetEnv(nexusOutputFileEnv, "")
+	concurrency, err := strconv.Atoi(cms.GetEnv(nexusConcurrencyEnv, "1"))
+	if err != nil {
+		log.Fatalf("invalid %v value %v", nexusConcurrencyEnv, err.Error())
+	}
+
+	mode := cms.GetEnv(nexusModeEnv, "apply")
+	if mode != "apply" && mode != "export" {
+		log.Fatalf("invalid %v value %v", nexusModeEnv, mode)
+	}
 
 	// read config file
 	data := ArtifactoryConfig{}
-	err := cms.DecodeFromFile(configFile, &data)
-	if err != nil {
-		log.Fatalf("reading configFile %v", err.Error())
+	if mode == "apply" {
+		err = cms.DecodeFromFile(configFile, &data)
+		if err != nil {
+			log.Fatalf("reading configFile %v", err.Error())
+		}
 	}
 
 	log.Printf("check and wait for nexus on host: %v", host)
@@ -210,35 +287,177 @@ func main() {
 		log.Println("host not ready, 3s")
 	}
 
-	log.Printf("installing (%d) hosted repositories", len(data.Hosteds))
-	for _, h := range data.Hosteds {
-		nexusConfig := nexusConfig(hostedDataConfig(h))
-		err := nexusPost(user, pass, host, nexusConfig)
+	client, err := newNexusClient(user, pass, host)
+	if err != nil {
+		log.Fatalf("connecting to nexus %v", err.Error())
+	}
+	if mode == "export" {
+		exportFile := cms.GetEnv(nexusExportFileEnv, "configFile.json")
+		repos, err := client.exportRepositories()
+		if err != nil {
+			log.Fatalf("reading repositories %v", err.Error())
+		}
+		err = cms.EncodeToFile(exportFile, exportConfig(repos), 0644)
 		if err != nil {
-			log.Println(err.Error())
+			log.Fatalf("writing export file %v", err.Error())
+		}
+		log.Printf("(%d) repositories exported to %v", len(repos), exportFile)
+		return
+	}
+
+	existing, err := client.repositories()
+	if err != nil {
+		log.Printf("reading existing repositories %v, group members are not verified", err.Error())
+	}
+	existingUsers, err := client.users()
+	if err != nil {
+		log.Printf("reading existing users %v, new users are expected", err.Error())
+	}
+	existingPolicies, _, err := client.cleanupPolicies()
+	if err != nil {
+		log.Printf("reading existing cleanup policies %v, repository policies are not verified", err.Error())
+	}
+	err = validateGroups(data, existing)
+	if err == nil {
+		err = validateHosteds(data)
+	}
+	if err == nil {
+		err = validateProxies(data)
+	}
+	if err == nil {
+		err = validateRoles(data)
+	}
+	if err == nil {
+		err = validateUsers(data, existingUsers, outputFile)
+	}
+	if err == nil {
+		err = validateSecurity(data)
+	}
+	if err == nil {
+		err = validateHTTPSettings(data)
+	}
+	if err == nil {
+		err = validateTrustStore(data)
+	}
+	if err == nil {
+		err = validateCleanupPolicies(data, existingPolicies)
+	}
+	if err == nil {
+		err = validateTasks(data)
+	}
+	if err == nil {
+		err = validateUploads(data)
+	}
+	if err == nil {
+		err = validateClientConfig(data, existing, existingUsers)
+	}
+	if err == nil {
+		err = validateVerify(data)
+	}
+	if err != nil {
+		log.Fatalf("invalid configFile %v", err.Error())
+	}
+	for _, w := range configWarnings(data) {
+		log.Printf("warning: %v", w)
+	}
+
+	err = client.applySecurity(data.Security)
+	if err != nil {
+		log.Printf("applying security settings %v", err.Error())
+	}
+	err = client.applyHTTPSettings(data.HTTPSettings)
+	if err != nil {
+		log.Printf("applying http settings %v", err.Error())
+	}
+	err = client.applyTrustStore(data.TrustStore)
+	if err != nil {
+		log.Printf("applying trust store %v", err.Error())
+	}
+	log.Printf("installing (%d) cleanup policies", len(data.CleanupPolicies))
+	err = client.applyCleanupPolicies(data.CleanupPolicies)
+	if err != nil {
+		log.Printf("applying cleanup policies %v", err.Error())
+	}
+
+	log.Printf("installing (%d) hosted, (%d) proxy and (%d) group repositories, %d at the same time",
+		len(data.Hosteds), len(data.Proxies), len(data.Groups), concurrency)
+	runTasks(repositoryTasks(client, data, existing), concurrency)
+
+	log.Printf("uploading (%d) paths", len(data.Uploads))
+	err = client.applyUploads(data)
+	if err != nil {
+		log.Printf("uploading artifacts %v", err.Error())
+	}
+
+	log.Printf("installing (%d) tasks", len(data.Tasks))
+	err = client.applyTasks(data.Tasks)
+	if err != nil {
+		log.Printf("applying tasks %v", err.Error())
+	}
+
+	log.Printf("installing (%d) roles", len(data.Roles))
+	runTasks(roleTasks(client, data), concurrency)
+
+	log.Printf("installing (%d) users", len(data.Users))
+	report := Report{Users: []UserCredentials{}}
+	// the report is written after each user so a later failure keeps the generated passwords
+	runTasks(userTasks(client, data, func(cred UserCredentials) {
+		report.Users = append(report.Users, cred)
+		if err := writeReport(outputFile, report); err != nil {
+			log.Printf("writing output file %v", err.Error())
 		}
-		log.Printf("repository '%v' created\n", h.Name)
+	}), concurrency)
+
+	err = writeClientConfig(data, host, &report)
+	if err != nil {
+		log.Printf("writing client config %v", err.Error())
 	}
 
-	log.Printf("installing (%d) proxy repositories", len(data.Proxies))
-	for _, p := range data.Proxies {
-		nexusConfig := nexusConfig(proxyDataConfig(p))
-		err := nexusPost(user, pass, host, nexusConfig)
+	failed := 0
+	if data.Verify != nil {
+		log.Printf("verifying (%d) proxy and (%d) group repositories", len(data.Proxies), len(data.Groups))
+		report.Verify, err = client.verifyRepositories(data, concurrency)
 		if err != nil {
-			log.Println(err.Error())
+			log.Printf("verifying repositories %v", err.Error())
+			failed++
+		}
+		for _, r := range report.Verify {
+			log.Printf("verify %v repository %v %v %v", r.Type, r.Repository, r.Status, r.Message)
+			if r.Status == verifyFailed || r.Status == verifyBlocked {
+				failed++
+			}
 		}
-		log.Printf("repository '%v' created\n", p.Name)
 	}
 
-	log.Printf("installing (%d) group repositories", len(data.Groups))
-	for _, g := range data.Groups {
-		nexusConfig := nexusConfig(groupDataConfig(g))
-		err := nexusPost(user, pass, host, nexusConfig)
+	if outputFile != "" {
+		err = writeReport(outputFile, report)
 		if err != nil {
-			log.Println(err.Error())
+			log.Fatalf("writing output file %v", err.Error())
 		}
-		log.Printf("repository '%v' created\n", g.Name)
+		log.Printf("output written to %v", outputFile)
+	}
+
+	if data.Verify != nil && data.Verify.Required && failed > 0 {
+		log.Fatalf("(%d) repositories failed the verification", failed)
 	}
 
 	log.Println("the job has finished successfully!")
 }
+
+// writeReport writes the report in the output file, nothing is written when the file is empty
+func writeReport(outputFile string, report Report) error {
+	if outputFile == "" {
+		return nil
+	}
+	return cms.EncodeToFile(outputFile, report, 0600)
+}
+
+// runTasks runs the tasks and logs the errors
+func runTasks(tasks []*cms.Task, concurrency int) {
+	cms.RunTasks(tasks, concurrency)
+	for _, t := range tasks {
+		if t.Err != nil {
+			log.Println(t.Err.Error())
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// privilegeActions are the actions of the repository view privileges
+var privilegeActions = map[string]bool{"browse": true, "read": true, "edit": true, "add": true, "delete": true, "*": true}
+
+// Role represents a role in the nexus security API
+type Role struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Privileges  []string `json:"privileges"`
+	Roles       []string `json:"roles"`
+	// Source and Version are only used by ExtDirect
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// validateRoles checks the ids of the roles, the repository privileges
+// and that the nested roles of the config don't have cycles
+func validateRoles(c ArtifactoryConfig) error {
+	deps := map[string][]string{}
+	order := []string{}
+	for _, r := range c.Roles {
+		if r.ID == "" {
+			return fmt.Errorf("role %v without id", r.Name)
+		}
+		if _, ok := deps[r.ID]; ok {
+			return fmt.Errorf("role %v is defined more than once", r.ID)
+		}
+		for _, p := range r.RepositoryPrivileges {
+			if len(p.Repositories) == 0 || len(p.Actions) == 0 {
+				return fmt.Errorf("role %v has repository privileges without repositories or actions", r.ID)
+			}
+			for _, a := range p.Actions {
+				if !privilegeActions[strings.ToLower(a)] {
+					return fmt.Errorf("role %v has an unknown action %v", r.ID, a)
+				}
+			}
+		}
+		order = append(order, r.ID)
+		deps[r.ID] = r.Roles
+	}
+	if cycle := findCycle(order, deps); cycle != nil {
+		return fmt.Errorf("roles with a cycle %v", cycle)
+	}
+	return nil
+}
+
+// roleFromConfig returns the role with the privileges and the generated repository privileges
+func roleFromConfig(r ArtifactoryRole, c ArtifactoryConfig) Role {
+	formats := repositoryFormats(c)
+
+	role := Role{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Privileges:  append([]string{}, r.Privileges...),
+		Roles:       append([]string{}, r.Roles...),
+	}
+	if role.Name == "" {
+		role.Name = r.ID
+	}
+	for _, p := range r.RepositoryPrivileges {
+		for _, repo := range p.Repositories {
+			format := p.Format
+			if format == "" {
+				format = repositoryFormat(formats[repo])
+			}
+			for _, a := range p.Actions {
+				role.Privileges = append(role.Privileges,
+					fmt.Sprintf("nx-repository-view-%v-%v-%v", format, repo, strings.ToLower(a)))
+			}
+		}
+	}
+	return role
+}
+
+// roleTasks returns the tasks to create or update the roles of the config,
+// the task of a role depends on the tasks of its nested roles defined in the config
+func roleTasks(client *nexusClient, c ArtifactoryConfig) []*cms.Task {
+	tasks := []*cms.Task{}
+	byID := map[string]*cms.Task{}
+	for _, r := range c.Roles {
+		role := roleFromConfig(r, c)
+		t := cms.NewTask("role "+role.ID, func() error {
+			return client.saveRole(role)
+		})
+		tasks = append(tasks, t)
+		byID[role.ID] = t
+	}
+	for i, r := range c.Roles {
+		for _, nested := range r.Roles {
+			if dep, ok := byID[nested]; ok {
+				tasks[i].Deps = append(tasks[i].Deps, dep)
+			}
+		}
+	}
+	return tasks
+}
+
+// saveRole creates the role or updates it when it already exists
+func (c *nexusClient) saveRole(r Role) error {
+	existing, found, err := c.getRole(r.ID)
+	if err != nil {
+		return err
+	}
+	if found {
+		r.Version = existing.Version
+		err = c.updateRole(r)
+		if err != nil {
+			return err
+		}
+		log.Printf("role %v updated", r.ID)
+		return nil
+	}
+	err = c.createRole(r)
+	if err != nil {
+		return err
+	}
+	log.Printf("role %v created", r.ID)
+	return nil
+}
+
+// getRole returns the role with the id, found is false when the role doesn't exist
+func (c *nexusClient) getRole(id string) (role Role, found bool, err error) {
+	if c.extDirect {
+		resp, err := c.call("coreui_Role", "read")
+		if err != nil {
+			return role, false, err
+		}
+		roles := []Role{}
+		err = json.Unmarshal(resp.Result.Data, &roles)
+		if err != nil {
+			return role, false, err
+		}
+		for _, r := range roles {
+			if r.ID == id {
+				return r, true, nil
+			}
+		}
+		return role, false, nil
+	}
+
+	err = c.do(http.MethodGet, "/v1/security/roles/"+id, nil, &role)
+	if e, ok := err.(*StatusError); ok && e.Code == http.StatusNotFound {
+		return role, false, nil
+	}
+	return role, err == nil, err
+}
+
+// createRole creates a new role
+func (c *nexusClient) createRole(r Role) error {
+	if c.extDirect {
+		r.Source = "default"
+		_, err := c.call("coreui_Role", "create", r)
+		return err
+	}
+	return c.do(http.MethodPost, "/v1/security/roles", r, nil)
+}
+
+// updateRole replaces the configuration of an existing role
+func (c *nexusClient) updateRole(r Role) error {
+	if c.extDirect {
+		r.Source = "default"
+		_, err := c.call("coreui_Role", "update", r)
+		return err
+	}
+	r.Version = ""
+	return c.do(http.MethodPut, "/v1/security/roles/"+r.ID, r, nil)
+}
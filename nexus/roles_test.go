@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+var rolesConfig = ArtifactoryConfig{
+	Hosteds: []ArtifactoryHosted{{Name: "releases"}, {Name: "npm-internal", Format: "npm"}},
+	Roles: []ArtifactoryRole{
+		{
+			ID:   "deployer",
+			Name: "Deployer",
+			RepositoryPrivileges: []RepositoryPrivilege{
+				{Repositories: []string{"releases", "npm-internal"}, Actions: []string{"add", "EDIT"}},
+			},
+			Roles: []string{"developer"},
+		},
+		{
+			ID:                   "developer",
+			Privileges:           []string{"nx-search-read"},
+			RepositoryPrivileges: []RepositoryPrivilege{{Repositories: []string{"*"}, Format: "*", Actions: []string{"read", "browse"}}},
+		},
+	},
+}
+
+func TestRoleFromConfig(t *testing.T) {
+	role := roleFromConfig(rolesConfig.Roles[0], rolesConfig)
+	want := []string{
+		"nx-repository-view-maven2-releases-add",
+		"nx-repository-view-maven2-releases-edit",
+		"nx-repository-view-npm-npm-internal-add",
+		"nx-repository-view-npm-npm-internal-edit",
+	}
+	if !reflect.DeepEqual(role.Privileges, want) {
+		t.Errorf("privileges got: %v want: %v", role.Privileges, want)
+	}
+
+	role = roleFromConfig(rolesConfig.Roles[1], rolesConfig)
+	want = []string{"nx-search-read", "nx-repository-view-*-*-read", "nx-repository-view-*-*-browse"}
+	if !reflect.DeepEqual(role.Privileges, want) || role.Name != "developer" {
+		t.Errorf("role got: %+v want privileges: %v", role, want)
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	tests := map[string][]ArtifactoryRole{
+		"without id":     {{Name: "deployer"}},
+		"duplicated":     {{ID: "a"}, {ID: "a"}},
+		"unknown action": {{ID: "a", RepositoryPrivileges: []RepositoryPrivilege{{Repositories: []string{"r"}, Actions: []string{"write"}}}}},
+		"without action": {{ID: "a", RepositoryPrivileges: []RepositoryPrivilege{{Repositories: []string{"r"}}}}},
+		"cycle":          {{ID: "a", Roles: []string{"b"}}, {ID: "b", Roles: []string{"a"}}},
+	}
+	for name, roles := range tests {
+		if validateRoles(ArtifactoryConfig{Roles: roles}) == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+	if err := validateRoles(rolesConfig); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRoleTasks(t *testing.T) {
+	for _, server := range []string{"Nexus/3.21.1-01 (OSS)", "Nexus/3.12.0-01 (OSS)"} {
+		nexus := newFakeNexus(server)
+		srv := httptest.NewServer(nexus)
+		client, err := newNexusClient("admin", "admin123", srv.URL)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", server, err)
+		}
+
+		// the second run updates the roles
+		for i := 0; i < 2; i++ {
+			tasks := roleTasks(client, rolesConfig)
+			if len(tasks[0].Deps) != 1 || tasks[0].Deps[0] != tasks[1] {
+				t.Errorf("%v: deployer must depend on developer", server)
+			}
+			cms.RunTasks(tasks, 2)
+			for _, task := range tasks {
+				if task.Err != nil {
+					t.Errorf("%v: unexpected error in %v: %v", server, task.Name, task.Err)
+				}
+			}
+		}
+		if len(nexus.roles) != 2 || !strings.HasPrefix(nexus.roles["deployer"].Privileges[0], "nx-repository-view") {
+			t.Errorf("%v: roles got: %+v", server, nexus.roles)
+		}
+		srv.Close()
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// weekDays are the days of the weekly schedule as numbered by nexus
+var weekDays = map[string]int{
+	"sunday": 1, "monday": 2, "tuesday": 3, "wednesday": 4, "thursday": 5, "friday": 6, "saturday": 7,
+}
+
+// ScheduledTask represents a task in the coreui_Task ExtDirect methods,
+// nexus doesn't have a REST API to create tasks
+type ScheduledTask struct {
+	ID                    string            `json:"id"`
+	TypeID                string            `json:"typeId"`
+	Name                  string            `json:"name"`
+	Enabled               bool              `json:"enabled"`
+	AlertEmail            string            `json:"alertEmail"`
+	NotificationCondition string            `json:"notificationCondition"`
+	Schedule              string            `json:"schedule"`
+	CronExpression        string            `json:"cronExpression,omitempty"`
+	StartDate             string            `json:"startDate,omitempty"`
+	RecurringDays         []int             `json:"recurringDays,omitempty"`
+	TimeZoneOffset        string            `json:"timeZoneOffset"`
+	Properties            map[string]string `json:"properties"`
+}
+
+// validateTasks checks the names and schedules of the tasks
+func validateTasks(c ArtifactoryConfig) error {
+	names := map[string]bool{}
+	for _, t := range c.Tasks {
+		if t.Name == "" || t.TypeID == "" {
+			return fmt.Errorf("task %v requires name and typeId", t.Name)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("task %v is defined more than once", t.Name)
+		}
+		names[t.Name] = true
+		if _, err := scheduledTask(t, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduledTask returns the task of the config, the daily and weekly
+// schedules start at the time of the task in the day of now
+func scheduledTask(t ArtifactoryTask, now time.Time) (ScheduledTask, error) {
+	task := ScheduledTask{
+		TypeID:                t.TypeID,
+		Name:                  t.Name,
+		Enabled:               !t.Disabled,
+		AlertEmail:            t.AlertEmail,
+		NotificationCondition: "FAILURE",
+		Schedule:              t.Schedule,
+		TimeZoneOffset:        "+00:00",
+		Properties:            t.Properties,
+	}
+	if task.Properties == nil {
+		task.Properties = map[string]string{}
+	}
+
+	switch t.Schedule {
+	case "", "manual":
+		task.Schedule = "manual"
+	case "cron":
+		if t.Cron == "" {
+			return task, fmt.Errorf("task %v with cron schedule requires cron", t.Name)
+		}
+		task.CronExpression = t.Cron
+	case "daily", "weekly":
+		at := t.Time
+		if at == "" {
+			at = "00:00"
+		}
+		clock, err := time.Parse("15:04", at)
+		if err != nil {
+			return task, fmt.Errorf("task %v has an invalid time %v", t.Name, at)
+		}
+		now = now.UTC()
+		start := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+		task.StartDate = start.Format("2006-01-02T15:04:05")
+		if t.Schedule == "daily" {
+			break
+		}
+		if len(t.Days) == 0 {
+			return task, fmt.Errorf("task %v with weekly schedule requires days", t.Name)
+		}
+		for _, d := range t.Days {
+			n, ok := weekDays[strings.ToLower(d)]
+			if !ok {
+				return task, fmt.Errorf("task %v has an invalid day %v", t.Name, d)
+			}
+			task.RecurringDays = append(task.RecurringDays, n)
+		}
+	default:
+		return task, fmt.Errorf("task %v has an unknown schedule %v", t.Name, t.Schedule)
+	}
+	return task, nil
+}
+
+// applyTasks creates the tasks of the config or updates the tasks with the same name
+func (c *nexusClient) applyTasks(tasks []ArtifactoryTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	resp, err := c.call("coreui_Task", "read")
+	if err != nil {
+		return err
+	}
+	existing := []ScheduledTask{}
+	err = json.Unmarshal(resp.Result.Data, &existing)
+	if err != nil {
+		return err
+	}
+	ids := map[string]string{}
+	for _, t := range existing {
+		ids[t.Name] = t.ID
+	}
+
+	for _, t := range tasks {
+		task, err := scheduledTask(t, time.Now())
+		if err != nil {
+			return err
+		}
+		task.ID = ids[t.Name]
+		method, action := "create", "created"
+		if task.ID != "" {
+			method, action = "update", "updated"
+		}
+		_, err = c.call("coreui_Task", method, task)
+		if err != nil {
+			return err
+		}
+		log.Printf("task %v %v", t.Name, action)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduledTask(t *testing.T) {
+	now := time.Date(2018, 6, 1, 15, 30, 0, 0, time.FixedZone("CST", -6*3600))
+	tests := map[string]struct {
+		task ArtifactoryTask
+		want ScheduledTask
+	}{
+		"manual": {
+			task: ArtifactoryTask{Name: "compact", TypeID: "blobstore.compact", Properties: map[string]string{"blobstoreName": "default"}},
+			want: ScheduledTask{Schedule: "manual", Properties: map[string]string{"blobstoreName": "default"}},
+		},
+		"cron": {
+			task: ArtifactoryTask{Name: "cleanup", TypeID: "repository.cleanup", Schedule: "cron", Cron: "0 0 1 * * ?"},
+			want: ScheduledTask{Schedule: "cron", CronExpression: "0 0 1 * * ?", Properties: map[string]string{}},
+		},
+		"daily": {
+			task: ArtifactoryTask{Name: "metadata", TypeID: "repository.maven.rebuild-metadata", Schedule: "daily", Time: "02:15"},
+			want: ScheduledTask{Schedule: "daily", StartDate: "2018-06-01T02:15:00", Properties: map[string]string{}},
+		},
+		"weekly": {
+			task: ArtifactoryTask{Name: "index", TypeID: "repository.rebuild-index", Schedule: "weekly", Days: []string{"Sunday", "saturday"}},
+			want: ScheduledTask{Schedule: "weekly", StartDate: "2018-06-01T00:00:00", RecurringDays: []int{1, 7}, Properties: map[string]string{}},
+		},
+	}
+	for name, tt := range tests {
+		got, err := scheduledTask(tt.task, now)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", name, err)
+			continue
+		}
+		tt.want.Name, tt.want.TypeID = tt.task.Name, tt.task.TypeID
+		tt.want.Enabled, tt.want.NotificationCondition, tt.want.TimeZoneOffset = true, "FAILURE", "+00:00"
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got: %+v want: %+v", name, got, tt.want)
+		}
+	}
+
+	invalid := map[string]ArtifactoryTask{
+		"without cron": {Name: "a", Schedule: "cron"},
+		"time":         {Name: "a", Schedule: "daily", Time: "25:00"},
+		"without days": {Name: "a", Schedule: "weekly"},
+		"day":          {Name: "a", Schedule: "weekly", Days: []string{"someday"}},
+		"schedule":     {Name: "a", Schedule: "hourly"},
+	}
+	for name, task := range invalid {
+		if _, err := scheduledTask(task, now); err == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+	if validateTasks(ArtifactoryConfig{Tasks: []ArtifactoryTask{{Name: "a"}}}) == nil {
+		t.Error("must return an error without typeId")
+	}
+}
+
+// fakeTasks serves the coreui_Task ExtDirect action of a fakeNexus
+type fakeTasks struct {
+	mu    sync.Mutex
+	tasks map[string]ScheduledTask
+}
+
+func newFakeTasks(f *fakeNexus) *fakeTasks {
+	t := &fakeTasks{tasks: map[string]ScheduledTask{}}
+	f.handleAction("coreui_Task", t.extDirect)
+	return t
+}
+
+func (t *fakeTasks) extDirect(method string, data []json.RawMessage) (interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if method == "read" {
+		list := []ScheduledTask{}
+		for _, task := range t.tasks {
+			list = append(list, task)
+		}
+		return list, nil
+	}
+	task := ScheduledTask{}
+	json.Unmarshal(data[0], &task)
+	if _, ok := t.tasks[task.ID]; ok != (method == "update") {
+		return nil, fmt.Errorf("invalid task id %v", task.ID)
+	}
+	if task.ID == "" {
+		task.ID = fmt.Sprintf("task-%d", len(t.tasks)+1)
+	}
+	t.tasks[task.ID] = task
+	return nil, nil
+}
+
+func TestApplyTasks(t *testing.T) {
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	fake := newFakeTasks(nexus)
+	fake.tasks["existing"] = ScheduledTask{ID: "existing", Name: "compact", TypeID: "blobstore.compact"}
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tasks := []ArtifactoryTask{
+		{Name: "compact", TypeID: "blobstore.compact", Schedule: "cron", Cron: "0 0 2 * * ?", Properties: map[string]string{"blobstoreName": "default"}},
+		{Name: "cleanup", TypeID: "repository.cleanup", Schedule: "cron", Cron: "0 0 1 * * ?"},
+	}
+	for i := 0; i < 2; i++ {
+		if err = client.applyTasks(tasks); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if len(fake.tasks) != 2 || fake.tasks["existing"].Schedule != "cron" {
+		t.Errorf("tasks must be matched by name got: %+v", fake.tasks)
+	}
+	created, updated := nexus.bodies("coreui_Task.create"), nexus.bodies("coreui_Task.update")
+	if len(created) != 1 || len(updated) != 3 {
+		t.Fatalf("requests got: %v %v", created, updated)
+	}
+	assertPayload(t, "extdirect/task_create.json", created[0])
+	assertPayload(t, "extdirect/task_update.json", updated[0])
+}
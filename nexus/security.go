@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// validateSecurity checks that the active realms are not repeated
+func validateSecurity(c ArtifactoryConfig) error {
+	if c.Security == nil {
+		return nil
+	}
+	realms := map[string]bool{}
+	for _, r := range c.Security.Realms {
+		if r == "" || realms[r] {
+			return fmt.Errorf("security realm %q is empty or repeated", r)
+		}
+		realms[r] = true
+	}
+	return nil
+}
+
+// applySecurity sets the active realms and the anonymous access of the config
+func (c *nexusClient) applySecurity(s *ArtifactorySecurity) error {
+	if s == nil {
+		return nil
+	}
+	if len(s.Realms) > 0 {
+		err := c.setRealms(s.Realms)
+		if err != nil {
+			return err
+		}
+		log.Printf("active realms %v", s.Realms)
+	}
+	if s.Anonymous != nil {
+		a := *s.Anonymous
+		if a.UserID == "" {
+			a.UserID = "anonymous"
+		}
+		if a.RealmName == "" {
+			a.RealmName = "NexusAuthorizingRealm"
+		}
+		err := c.setAnonymous(a)
+		if err != nil {
+			return err
+		}
+		log.Printf("anonymous access enabled: %v", a.Enabled)
+	}
+	return nil
+}
+
+// setRealms sets the active realms in order
+func (c *nexusClient) setRealms(realms []string) error {
+	if c.extDirect {
+		_, err := c.call("coreui_RealmSettings", "update", map[string][]string{"realms": realms})
+		return err
+	}
+	return c.do(http.MethodPut, "/v1/security/realms/active", realms, nil)
+}
+
+// setAnonymous sets the anonymous access
+func (c *nexusClient) setAnonymous(a ArtifactoryAnonymous) error {
+	if c.extDirect {
+		_, err := c.call("coreui_AnonymousSettings", "update", a)
+		return err
+	}
+	return c.do(http.MethodPut, "/v1/security/anonymous", a, nil)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApplySecurity(t *testing.T) {
+	security := &ArtifactorySecurity{
+		Realms:    []string{"NexusAuthenticatingRealm", "NexusAuthorizingRealm", "NpmToken", "DockerToken"},
+		Anonymous: &ArtifactoryAnonymous{Enabled: true},
+	}
+	tests := map[string]map[string]string{
+		"Nexus/3.21.1-01 (OSS)": {
+			"/service/rest/v1/security/realms/active": `["NexusAuthenticatingRealm","NexusAuthorizingRealm","NpmToken","DockerToken"]`,
+			"/service/rest/v1/security/anonymous":     `{"enabled":true,"userId":"anonymous","realmName":"NexusAuthorizingRealm"}`,
+		},
+		"Nexus/3.12.0-01 (OSS)": {
+			"coreui_RealmSettings":     `{"realms":["NexusAuthenticatingRealm","NexusAuthorizingRealm","NpmToken","DockerToken"]}`,
+			"coreui_AnonymousSettings": `{"enabled":true,"userId":"anonymous","realmName":"NexusAuthorizingRealm"}`,
+		},
+	}
+	for server, want := range tests {
+		nexus := newFakeNexus(server)
+		srv := httptest.NewServer(nexus)
+		client, err := newNexusClient("admin", "admin123", srv.URL)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", server, err)
+		}
+		if err = client.applySecurity(security); err != nil {
+			t.Errorf("%v: unexpected error %v", server, err)
+		}
+		for key, body := range want {
+			if nexus.settings[key] != body {
+				t.Errorf("%v: %v got: %v want: %v", server, key, nexus.settings[key], body)
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestValidateSecurity(t *testing.T) {
+	config := ArtifactoryConfig{Security: &ArtifactorySecurity{Realms: []string{"NpmToken", "NpmToken"}}}
+	if validateSecurity(config) == nil {
+		t.Error("must return an error with repeated realms")
+	}
+	if err := validateSecurity(ArtifactoryConfig{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// trustStoreTimeout is the timeout to fetch the certificate of an endpoint
+const trustStoreTimeout = 10 * time.Second
+
+// trustedCertificate represents a certificate in the nexus trust store
+type trustedCertificate struct {
+	Fingerprint string `json:"fingerprint"`
+	Pem         string `json:"pem"`
+}
+
+// validateTrustStore checks the certificates and endpoints of the trust store
+func validateTrustStore(c ArtifactoryConfig) error {
+	if c.TrustStore == nil {
+		return nil
+	}
+	for i, p := range c.TrustStore.Certificates {
+		if _, err := parseCertificates([]byte(p)); err != nil {
+			return fmt.Errorf("trust_store certificate %d %v", i, err)
+		}
+	}
+	for _, e := range c.TrustStore.Endpoints {
+		if _, _, err := net.SplitHostPort(e); err != nil {
+			return fmt.Errorf("trust_store endpoint %v", err)
+		}
+	}
+	return nil
+}
+
+// trustStoreCertificates returns the certificates of the config,
+// the certificates of the endpoints are fetched
+func trustStoreCertificates(t ArtifactoryTrustStore) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for _, p := range t.Certificates {
+		parsed, err := parseCertificates([]byte(p))
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, parsed...)
+	}
+	for _, e := range t.Endpoints {
+		cert, err := fetchCertificate(e, trustStoreTimeout)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// parseCertificates returns the certificates of the PEM blocks in data
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("PEM without certificates")
+	}
+	return certs, nil
+}
+
+// fetchCertificate returns the certificate presented by the host:port endpoint,
+// the certificate is not verified because it is going to be trusted
+func fetchCertificate(endpoint string, timeout time.Duration) (*x509.Certificate, error) {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", endpoint, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching certificate of %v: %v", endpoint, err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("endpoint %v without certificates", endpoint)
+	}
+	return certs[0], nil
+}
+
+// fingerprint returns the SHA-1 fingerprint of the certificate as shown by nexus
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// applyTrustStore adds the certificates of the config to the nexus trust store,
+// the certificates already in the trust store are skipped
+func (c *nexusClient) applyTrustStore(t *ArtifactoryTrustStore) error {
+	if t == nil {
+		return nil
+	}
+	if c.extDirect {
+		return fmt.Errorf("the trust store requires the REST API")
+	}
+	certs, err := trustStoreCertificates(*t)
+	if err != nil {
+		return err
+	}
+
+	existing := []trustedCertificate{}
+	err = c.do(http.MethodGet, "/v1/security/ssl/truststore", nil, &existing)
+	if err != nil {
+		return err
+	}
+	trusted := map[string]bool{}
+	for _, e := range existing {
+		trusted[strings.ToUpper(e.Fingerprint)] = true
+	}
+
+	for _, cert := range certs {
+		fp := fingerprint(cert)
+		if trusted[fp] {
+			log.Printf("certificate %v (%v) already trusted", cert.Subject.CommonName, fp)
+			continue
+		}
+		err = c.addCertificate(cert)
+		if err != nil {
+			return err
+		}
+		trusted[fp] = true
+		log.Printf("certificate %v (%v) added to the trust store", cert.Subject.CommonName, fp)
+	}
+	return nil
+}
+
+// addCertificate adds the PEM of the certificate to the trust store
+func (c *nexusClient) addCertificate(cert *x509.Certificate) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	req, err := http.NewRequest(http.MethodPost, c.host+"/service/rest/v1/security/ssl/truststore", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.user, c.pass)
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &StatusError{Method: req.Method, URL: req.URL.String(), Code: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
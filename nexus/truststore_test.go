@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchCertificate(t *testing.T) {
+	remote := httptest.NewTLSServer(http.NotFoundHandler())
+	defer remote.Close()
+
+	cert, err := fetchCertificate(remote.Listener.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !cert.Equal(remote.Certificate()) {
+		t.Errorf("certificate got: %v want: %v", cert.Subject, remote.Certificate().Subject)
+	}
+	if len(fingerprint(cert)) != 59 || strings.ToUpper(fingerprint(cert)) != fingerprint(cert) {
+		t.Errorf("unexpected fingerprint %v", fingerprint(cert))
+	}
+
+	// a plain http server doesn't have certificates
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	if _, err = fetchCertificate(plain.Listener.Addr().String(), time.Second); err == nil {
+		t.Error("must return an error without TLS")
+	}
+}
+
+// fakeTrustStore serves the REST trust store of a fakeNexus
+type fakeTrustStore struct {
+	mu           sync.Mutex
+	certificates []string
+}
+
+func newFakeTrustStore(f *fakeNexus) *fakeTrustStore {
+	ts := &fakeTrustStore{}
+	f.handle("/service/rest/v1/security/ssl/truststore", ts.rest)
+	return ts
+}
+
+func (ts *fakeTrustStore) rest(w http.ResponseWriter, r *http.Request) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if r.Method == http.MethodPost {
+		body, _ := ioutil.ReadAll(r.Body)
+		ts.certificates = append(ts.certificates, string(body))
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	list := []trustedCertificate{}
+	for _, p := range ts.certificates {
+		certs, _ := parseCertificates([]byte(p))
+		list = append(list, trustedCertificate{Fingerprint: fingerprint(certs[0]), Pem: p})
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+func TestApplyTrustStore(t *testing.T) {
+	remote := httptest.NewTLSServer(http.NotFoundHandler())
+	defer remote.Close()
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	fake := newFakeTrustStore(nexus)
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	recorded, err := ioutil.ReadFile(filepath.Join("testdata", "rest", "truststore_certificate.pem"))
+	if err != nil {
+		t.Fatalf("reading fixture %v", err)
+	}
+	remotePEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: remote.Certificate().Raw}))
+	trustStore := &ArtifactoryTrustStore{
+		Certificates: []string{string(recorded), remotePEM},
+		Endpoints:    []string{remote.Listener.Addr().String()},
+	}
+	if err = validateTrustStore(ArtifactoryConfig{TrustStore: trustStore}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	// the second run and the endpoint with the same certificate are skipped
+	for i := 0; i < 2; i++ {
+		if err = client.applyTrustStore(trustStore); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	bodies := nexus.bodies("POST /service/rest/v1/security/ssl/truststore")
+	if len(bodies) != 2 || bodies[0] != string(recorded) || bodies[1] != remotePEM {
+		t.Errorf("trust store requests got: %v", bodies)
+	}
+	if len(fake.certificates) != 2 {
+		t.Errorf("trust store got: %v", fake.certificates)
+	}
+}
+
+func TestValidateTrustStore(t *testing.T) {
+	tests := map[string]*ArtifactoryTrustStore{
+		"pem":      {Certificates: []string{"-----BEGIN CERTIFICATE-----\nbad\n-----END CERTIFICATE-----\n"}},
+		"empty":    {Certificates: []string{"not a certificate"}},
+		"endpoint": {Endpoints: []string{"nexus.corp.local"}},
+	}
+	for name, ts := range tests {
+		if validateTrustStore(ArtifactoryConfig{TrustStore: ts}) == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+}
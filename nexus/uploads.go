@@ -0,0 +1,527 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mavenTimestamp matches the timestamp of the snapshot files e.g. 20200101.123456-1
+var mavenTimestamp = regexp.MustCompile(`^\d{8}\.\d{6}-\d+`)
+
+// mavenIgnored are the suffixes of the files of a local maven repository that are not artifacts
+var mavenIgnored = []string{".md5", ".sha1", ".sha256", ".sha512", ".lastUpdated", "_remote.repositories", "resolver-status.properties"}
+
+// component represents the assets uploaded in one request to the components API
+type component struct {
+	Name string
+	// Fields are the form fields of the request
+	Fields map[string]string
+	// Files are the form files of the request by field name
+	Files map[string]string
+	// Paths are the paths of the files in the repository by field name
+	Paths map[string]string
+}
+
+// validateUploads checks the repositories, formats and paths of the uploads
+func validateUploads(c ArtifactoryConfig) error {
+	repos := map[string]string{}
+	for _, r := range repositoryNames(c) {
+		repos[r] = ""
+	}
+	for _, h := range c.Hosteds {
+		repos[h.Name] = typeHosted
+	}
+	for _, u := range c.Uploads {
+		if u.Repository == "" || u.Path == "" {
+			return fmt.Errorf("upload %v requires repository and path", u.Path)
+		}
+		if typ, ok := repos[u.Repository]; ok && typ != typeHosted {
+			return fmt.Errorf("upload %v requires a hosted repository, %v is not hosted", u.Path, u.Repository)
+		}
+		switch uploadFormat(u, c) {
+		case formatMaven, "npm", "raw":
+		default:
+			return fmt.Errorf("upload %v has an unknown format %v", u.Path, u.Format)
+		}
+		if _, err := os.Stat(u.Path); err != nil {
+			return fmt.Errorf("upload %v", err)
+		}
+	}
+	return nil
+}
+
+// uploadFormat returns the format of the upload, by default the format of its repository
+func uploadFormat(u ArtifactoryUpload, c ArtifactoryConfig) string {
+	if u.Format != "" {
+		return u.Format
+	}
+	for _, h := range c.Hosteds {
+		if h.Name == u.Repository {
+			return repositoryFormat(h.Format)
+		}
+	}
+	return formatMaven
+}
+
+// uploadComponents returns the components of the upload
+func uploadComponents(u ArtifactoryUpload, format string) ([]component, error) {
+	root, files, err := listFiles(u.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case formatMaven:
+		if root != u.Path {
+			return mavenFileComponents(root, files[0])
+		}
+		return mavenComponents(root, files)
+	case "npm":
+		components := []component{}
+		for _, f := range files {
+			if !strings.HasSuffix(f, ".tgz") {
+				continue
+			}
+			file := filepath.Join(root, filepath.FromSlash(f))
+			assetPath, err := npmAssetPath(file)
+			if err != nil {
+				return nil, err
+			}
+			components = append(components, component{
+				Name:  f,
+				Files: map[string]string{"npm.asset": file},
+				Paths: map[string]string{"npm.asset": assetPath},
+			})
+		}
+		return components, nil
+	default:
+		components := []component{}
+		for _, f := range files {
+			dir := path.Join("/", u.Directory, path.Dir(f))
+			components = append(components, component{
+				Name:   path.Join(dir, path.Base(f)),
+				Fields: map[string]string{"raw.directory": dir, "raw.asset1.filename": path.Base(f)},
+				Files:  map[string]string{"raw.asset1": filepath.Join(root, filepath.FromSlash(f))},
+				Paths:  map[string]string{"raw.asset1": strings.TrimPrefix(path.Join(dir, path.Base(f)), "/")},
+			})
+		}
+		return components, nil
+	}
+}
+
+// listFiles returns the directory of the files and the regular files in it
+// relative to the directory with slashes, when file is a regular file the
+// directory is its parent and the files only contain its name
+func listFiles(file string) (string, []string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.Mode().IsRegular() {
+		return filepath.Dir(file), []string{info.Name()}, nil
+	}
+
+	files := []string{}
+	err = filepath.Walk(file, func(f string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(file, f)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(files)
+	return file, files, nil
+}
+
+// mavenComponents groups the files of a maven repository layout
+// group/path/artifactId/version/artifactId-version[-classifier].extension
+// by their coordinates, the coordinates of a component with a pom are read from the pom
+func mavenComponents(root string, files []string) ([]component, error) {
+	components := []component{}
+	byGAV := map[string]int{}
+	for _, f := range files {
+		if mavenIgnoredFile(f) {
+			continue
+		}
+		parts := strings.Split(f, "/")
+		n := len(parts)
+		if n < 4 {
+			return nil, fmt.Errorf("file %v is not in a maven repository layout", f)
+		}
+		group := strings.Join(parts[:n-3], ".")
+		artifact, version, name := parts[n-3], parts[n-2], parts[n-1]
+		if timestampedSnapshot(name, artifact, version) {
+			log.Printf("skipping %v, timestamped snapshots are not uploaded", f)
+			continue
+		}
+		classifier, extension, err := mavenAsset(name, artifact, version)
+		if err != nil {
+			return nil, err
+		}
+
+		gav := group + ":" + artifact + ":" + version
+		i, ok := byGAV[gav]
+		if !ok {
+			components = append(components, component{
+				Name: gav,
+				Fields: map[string]string{
+					"maven2.groupId":      group,
+					"maven2.artifactId":   artifact,
+					"maven2.version":      version,
+					"maven2.generate-pom": "false",
+				},
+				Files: map[string]string{},
+				Paths: map[string]string{},
+			})
+			i = len(components) - 1
+			byGAV[gav] = i
+		}
+		addMavenAsset(components[i], filepath.Join(root, filepath.FromSlash(f)), f, classifier, extension)
+	}
+
+	for i := range components {
+		c := &components[i]
+		if hasPom(*c) {
+			delete(c.Fields, "maven2.groupId")
+			delete(c.Fields, "maven2.artifactId")
+			delete(c.Fields, "maven2.version")
+			delete(c.Fields, "maven2.generate-pom")
+		}
+	}
+	return components, nil
+}
+
+// mavenFileComponents returns the component of a single file in the version
+// directory dir of a maven repository layout, the groupId is not in the path so
+// it is read from the file when it is the pom or from the pom in dir
+func mavenFileComponents(dir, name string) ([]component, error) {
+	if mavenIgnoredFile(name) {
+		return []component{}, nil
+	}
+	version := filepath.Base(dir)
+	artifact := filepath.Base(filepath.Dir(dir))
+	if timestampedSnapshot(name, artifact, version) {
+		log.Printf("skipping %v, timestamped snapshots are not uploaded", filepath.Join(dir, name))
+		return []component{}, nil
+	}
+	classifier, extension, err := mavenAsset(name, artifact, version)
+	if err != nil {
+		return nil, err
+	}
+	c := component{
+		Name:   artifact + ":" + version,
+		Fields: map[string]string{},
+		Files:  map[string]string{},
+		Paths:  map[string]string{},
+	}
+	pom := filepath.Join(dir, name)
+	addMavenAsset(c, pom, name, classifier, extension)
+	if !hasPom(c) {
+		pom = filepath.Join(dir, artifact+"-"+version+".pom")
+		if _, err := os.Stat(pom); err != nil {
+			return nil, fmt.Errorf("file %v requires the pom %v", filepath.Join(dir, name), pom)
+		}
+		addMavenAsset(c, pom, filepath.Base(pom), "", "pom")
+	}
+
+	group, err := pomGroupID(pom)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Replace(group, ".", "/", -1) + "/" + artifact + "/" + version + "/"
+	for field, p := range c.Paths {
+		c.Paths[field] = prefix + p
+	}
+	return []component{c}, nil
+}
+
+// pomGroupID returns the groupId of the pom, or the groupId of its parent
+func pomGroupID(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	pom := struct {
+		GroupID string `xml:"groupId"`
+		Parent  struct {
+			GroupID string `xml:"groupId"`
+		} `xml:"parent"`
+	}{}
+	if err = xml.Unmarshal(data, &pom); err != nil {
+		return "", fmt.Errorf("pom %v %v", file, err)
+	}
+	if pom.GroupID == "" {
+		pom.GroupID = pom.Parent.GroupID
+	}
+	if pom.GroupID == "" {
+		return "", fmt.Errorf("pom %v without groupId", file)
+	}
+	return pom.GroupID, nil
+}
+
+// timestampedSnapshot returns true when the file name has a timestamp
+// instead of the SNAPSHOT version e.g. app-1.0-20200101.123456-1.jar
+func timestampedSnapshot(name, artifact, version string) bool {
+	base := strings.TrimSuffix(version, "-SNAPSHOT")
+	prefix := artifact + "-" + base + "-"
+	return base != version && strings.HasPrefix(name, prefix) && mavenTimestamp.MatchString(name[len(prefix):])
+}
+
+// mavenAsset returns the classifier and extension of the file name
+// artifactId-version[-classifier].extension
+func mavenAsset(name, artifact, version string) (string, string, error) {
+	prefix := artifact + "-" + version
+	if !strings.HasPrefix(name, prefix) {
+		return "", "", fmt.Errorf("file %v doesn't match %v", name, prefix)
+	}
+	rest := name[len(prefix):]
+	classifier := ""
+	if strings.HasPrefix(rest, "-") {
+		i := strings.Index(rest, ".")
+		if i < 0 {
+			return "", "", fmt.Errorf("file %v without extension", name)
+		}
+		classifier, rest = rest[1:i], rest[i:]
+	}
+	if !strings.HasPrefix(rest, ".") || len(rest) == 1 {
+		return "", "", fmt.Errorf("file %v without extension", name)
+	}
+	return classifier, rest[1:], nil
+}
+
+// addMavenAsset adds the file as the next asset of the component
+// with its path in the repository
+func addMavenAsset(c component, file, assetPath, classifier, extension string) {
+	field := "maven2.asset" + strconv.Itoa(len(c.Files)+1)
+	c.Files[field] = file
+	c.Paths[field] = assetPath
+	c.Fields[field+".extension"] = extension
+	if classifier != "" {
+		c.Fields[field+".classifier"] = classifier
+	}
+}
+
+// hasPom returns true when the component contains its pom
+func hasPom(c component) bool {
+	for field := range c.Files {
+		if c.Fields[field+".extension"] == "pom" && c.Fields[field+".classifier"] == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// mavenIgnoredFile returns true for the files of a local maven repository that are not artifacts
+func mavenIgnoredFile(f string) bool {
+	name := path.Base(f)
+	if strings.HasPrefix(name, "maven-metadata") {
+		return true
+	}
+	for _, s := range mavenIgnored {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// npmAssetPath returns the path of the npm tarball in the repository,
+// name/-/name-version.tgz with the name and version of its package.json
+func npmAssetPath(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	p, err := npmPackagePath(f)
+	if err != nil {
+		return "", fmt.Errorf("npm tarball %v %v", file, err)
+	}
+	return p, nil
+}
+
+// npmPackagePath returns the repository path of the npm tarball read from r
+func npmPackagePath(r io.Reader) (string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("without package.json")
+		}
+		if err != nil {
+			return "", err
+		}
+		// the package.json is in the top directory, usually package/
+		if path.Base(h.Name) != "package.json" || strings.Count(strings.Trim(h.Name, "/"), "/") != 1 {
+			continue
+		}
+		pkg := struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}{}
+		if err = json.NewDecoder(tr).Decode(&pkg); err != nil {
+			return "", err
+		}
+		if pkg.Name == "" || pkg.Version == "" {
+			return "", fmt.Errorf("package.json without name or version")
+		}
+		return pkg.Name + "/-/" + path.Base(pkg.Name) + "-" + pkg.Version + ".tgz", nil
+	}
+}
+
+// applyUploads uploads the components of each upload skipping
+// the components whose files are already in the repository
+func (c *nexusClient) applyUploads(cfg ArtifactoryConfig) error {
+	for _, u := range cfg.Uploads {
+		components, err := uploadComponents(u, uploadFormat(u, cfg))
+		if err != nil {
+			return err
+		}
+		for _, comp := range components {
+			exists, err := c.componentExists(u.Repository, comp)
+			if err != nil {
+				return err
+			}
+			if exists {
+				log.Printf("%v already in %v", comp.Name, u.Repository)
+				continue
+			}
+			err = c.uploadComponent(u.Repository, comp)
+			if err != nil {
+				return err
+			}
+			log.Printf("%v uploaded to %v", comp.Name, u.Repository)
+		}
+	}
+	return nil
+}
+
+// componentExists returns true when all the files of the component
+// are assets of the repository in the same path with the same sha1 checksum
+func (c *nexusClient) componentExists(repo string, comp component) (bool, error) {
+	for field, f := range comp.Files {
+		sum, err := sha1File(f)
+		if err != nil {
+			return false, err
+		}
+		result := struct {
+			Items []struct {
+				Path string `json:"path"`
+			} `json:"items"`
+		}{}
+		q := url.Values{"repository": {repo}, "sha1": {sum}}
+		err = c.do(http.MethodGet, "/v1/search/assets?"+q.Encode(), nil, &result)
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, item := range result.Items {
+			if strings.TrimPrefix(item.Path, "/") == comp.Paths[field] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// uploadComponent uploads the component into the repository with a multipart request
+func (c *nexusClient) uploadComponent(repo string, comp component) error {
+	r, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		w.CloseWithError(writeForm(form, comp))
+	}()
+
+	req, err := http.NewRequest(http.MethodPost,
+		c.host+"/service/rest/v1/components?repository="+url.QueryEscape(repo), r)
+	if err != nil {
+		r.Close()
+		return err
+	}
+	req.SetBasicAuth(c.user, c.pass)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		r.Close()
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &StatusError{Method: req.Method, URL: req.URL.String(), Code: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
+
+// writeForm writes the fields and files of the component in the form
+func writeForm(form *multipart.Writer, comp component) error {
+	for k, v := range comp.Fields {
+		if err := form.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	for field, file := range comp.Files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		part, err := form.CreateFormFile(field, filepath.Base(file))
+		if err == nil {
+			_, err = io.Copy(part, f)
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
+
+// sha1File returns the hex sha1 checksum of the file
+func sha1File(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeComponents serves the components and assets search REST APIs of a fakeNexus
+type fakeComponents struct {
+	mu sync.Mutex
+	// assets contains the sha1 of the assets by repository and path
+	// and uploads the form fields of each component upload
+	assets  map[string]map[string]string
+	uploads []map[string]string
+}
+
+func newFakeComponents(f *fakeNexus) *fakeComponents {
+	c := &fakeComponents{assets: map[string]map[string]string{}}
+	f.handle("/service/rest/v1/components", c.upload)
+	f.handle("/service/rest/v1/search/assets", c.search)
+	return c
+}
+
+func (c *fakeComponents) search(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	repo, sum := r.URL.Query().Get("repository"), r.URL.Query().Get("sha1")
+	items := []map[string]interface{}{}
+	for p, s := range c.assets[repo] {
+		if s == sum {
+			items = append(items, map[string]interface{}{"path": p, "repository": repo, "checksum": map[string]string{"sha1": s}})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "continuationToken": nil})
+}
+
+func (c *fakeComponents) upload(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	repo := r.URL.Query().Get("repository")
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields := map[string]string{}
+	for k, v := range r.MultipartForm.Value {
+		fields[k] = v[0]
+	}
+	if c.assets[repo] == nil {
+		c.assets[repo] = map[string]string{}
+	}
+	names, data := map[string]string{}, map[string][]byte{}
+	for k, files := range r.MultipartForm.File {
+		file, _ := files[0].Open()
+		data[k], _ = ioutil.ReadAll(file)
+		file.Close()
+		names[k] = files[0].Filename
+	}
+	paths, err := uploadedPaths(fields, names, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for k, p := range paths {
+		sum := sha1.Sum(data[k])
+		c.assets[repo][p] = hex.EncodeToString(sum[:])
+		fields[k] = names[k]
+	}
+	c.uploads = append(c.uploads, fields)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// uploadedPaths returns the paths nexus gives to the uploaded files by field, the maven
+// coordinates are read from the fields or the pom and the npm ones from the tarball
+func uploadedPaths(fields, names map[string]string, data map[string][]byte) (map[string]string, error) {
+	paths := map[string]string{}
+	if _, ok := data["npm.asset"]; ok {
+		p, err := npmPackagePath(bytes.NewReader(data["npm.asset"]))
+		paths["npm.asset"] = p
+		return paths, err
+	}
+	if _, ok := data["raw.asset1"]; ok {
+		paths["raw.asset1"] = strings.TrimPrefix(path.Join(fields["raw.directory"], fields["raw.asset1.filename"]), "/")
+		return paths, nil
+	}
+
+	pom := struct {
+		GroupID    string `xml:"groupId"`
+		ArtifactID string `xml:"artifactId"`
+		Version    string `xml:"version"`
+	}{fields["maven2.groupId"], fields["maven2.artifactId"], fields["maven2.version"]}
+	for k := range data {
+		if fields[k+".extension"] == "pom" && fields[k+".classifier"] == "" {
+			if err := xml.Unmarshal(data[k], &pom); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if pom.GroupID == "" {
+		return nil, fmt.Errorf("upload without groupId")
+	}
+	for k := range data {
+		paths[k] = strings.Replace(pom.GroupID, ".", "/", -1) + "/" + pom.ArtifactID + "/" + pom.Version + "/" + names[k]
+	}
+	return paths, nil
+}
+
+// appPom is the pom of the io.tikal:app:1.0 test artifact
+const appPom = "<project><groupId>io.tikal</groupId><artifactId>app</artifactId><version>1.0</version></project>"
+
+// npmTarball returns a gzipped tarball with the package.json of the package
+func npmTarball(t *testing.T, name, version string) string {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	pkg := fmt.Sprintf(`{"name":%q,"version":%q}`, name, version)
+	tw.WriteHeader(&tar.Header{Name: "package/package.json", Mode: 0644, Size: int64(len(pkg))})
+	tw.Write([]byte(pkg))
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	return buf.String()
+}
+
+// writeFiles creates the files with their content in dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMavenComponents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"io/tikal/app/1.0/app-1.0.pom":                "pom",
+		"io/tikal/app/1.0/app-1.0.jar":                "jar",
+		"io/tikal/app/1.0/app-1.0-sources.jar":        "sources",
+		"io/tikal/app/1.0/app-1.0.jar.sha1":           "sha1",
+		"io/tikal/app/1.0/_remote.repositories":       "",
+		"io/tikal/app/maven-metadata-central.xml":     "",
+		"io/tikal/lib/2.0/lib-2.0.tar.gz":             "lib",
+		"io/tikal/lib/2.0/lib-2.0.tar.gz.lastUpdated": "",
+		// timestamped snapshots are skipped
+		"io/tikal/app/1.1-SNAPSHOT/app-1.1-20200101.123456-1.jar": "snapshot",
+		"io/tikal/app/1.1-SNAPSHOT/app-1.1-20200101.123456-1.pom": "snapshot",
+	})
+
+	root, files, err := listFiles(dir)
+	if err != nil || root != dir {
+		t.Fatalf("unexpected root %v error %v", root, err)
+	}
+	components, err := mavenComponents(root, files)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(components) != 2 {
+		t.Fatalf("components got: %v want: 2", len(components))
+	}
+	app, lib := components[0], components[1]
+	if app.Name != "io.tikal:app:1.0" || len(app.Files) != 3 {
+		t.Errorf("unexpected component %v", app)
+	}
+	// the coordinates are read from the pom
+	if _, ok := app.Fields["maven2.groupId"]; ok {
+		t.Errorf("coordinates with a pom %v", app.Fields)
+	}
+	want := map[string]string{
+		"maven2.groupId":          "io.tikal",
+		"maven2.artifactId":       "lib",
+		"maven2.version":          "2.0",
+		"maven2.generate-pom":     "false",
+		"maven2.asset1.extension": "tar.gz",
+	}
+	if !reflect.DeepEqual(lib.Fields, want) {
+		t.Errorf("fields got: %v want: %v", lib.Fields, want)
+	}
+	if p := lib.Paths["maven2.asset1"]; p != "io/tikal/lib/2.0/lib-2.0.tar.gz" {
+		t.Errorf("path got: %v", p)
+	}
+	snapshot, err := mavenFileComponents(filepath.Join(dir, "io/tikal/app/1.1-SNAPSHOT"), "app-1.1-20200101.123456-1.jar")
+	if err != nil || len(snapshot) != 0 {
+		t.Errorf("timestamped snapshot got: %v %v", snapshot, err)
+	}
+
+	if _, err = mavenComponents(dir, []string{"app-1.0.jar"}); err == nil {
+		t.Error("must return an error out of the maven layout")
+	}
+	if _, err = mavenComponents(dir, []string{"io/app/1.0/other-1.0.jar"}); err == nil {
+		t.Error("must return an error with a different artifactId")
+	}
+}
+
+func TestValidateUploads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := ArtifactoryConfig{
+		Hosteds: []ArtifactoryHosted{{Name: "npm-hosted", Format: "npm"}},
+		Proxies: []ArtifactoryProxy{{Name: "central"}},
+	}
+	tests := []struct {
+		upload ArtifactoryUpload
+		valid  bool
+	}{
+		{ArtifactoryUpload{Repository: "npm-hosted", Path: dir}, true},
+		{ArtifactoryUpload{Repository: "releases", Path: dir, Format: "raw"}, true},
+		{ArtifactoryUpload{Repository: "releases"}, false},
+		{ArtifactoryUpload{Repository: "central", Path: dir}, false},
+		{ArtifactoryUpload{Repository: "releases", Path: dir, Format: "docker"}, false},
+		{ArtifactoryUpload{Repository: "releases", Path: filepath.Join(dir, "missing")}, false},
+	}
+	for _, test := range tests {
+		c.Uploads = []ArtifactoryUpload{test.upload}
+		err := validateUploads(c)
+		if (err == nil) != test.valid {
+			t.Errorf("upload %v valid: %v got error: %v", test.upload, test.valid, err)
+		}
+	}
+	if f := uploadFormat(ArtifactoryUpload{Repository: "npm-hosted"}, c); f != "npm" {
+		t.Errorf("format got: %v want: npm", f)
+	}
+}
+
+func TestApplyUploads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"maven/io/tikal/app/1.0/app-1.0.pom": appPom,
+		"maven/io/tikal/app/1.0/app-1.0.jar": "jar",
+		"npm/app-1.0.0.tgz":                  npmTarball(t, "@tikal/app", "1.0.0"),
+		"npm/README.md":                      "readme",
+		"raw/docs/index.html":                "index",
+	})
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	fake := newFakeComponents(nexus)
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	c := ArtifactoryConfig{Uploads: []ArtifactoryUpload{
+		{Repository: "releases", Path: filepath.Join(dir, "maven")},
+		{Repository: "npm-hosted", Format: "npm", Path: filepath.Join(dir, "npm")},
+		{Repository: "site", Format: "raw", Path: filepath.Join(dir, "raw"), Directory: "static"},
+	}}
+	// the second run skips the artifacts with the same checksum
+	for i := 0; i < 2; i++ {
+		if err = client.applyUploads(c); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if len(fake.uploads) != 3 {
+		t.Fatalf("uploads got: %v want: 3", fake.uploads)
+	}
+	if fake.uploads[0]["maven2.asset1.extension"] == "" || fake.uploads[0]["maven2.asset2.extension"] == "" {
+		t.Errorf("unexpected maven upload %v", fake.uploads[0])
+	}
+	if fake.uploads[1]["npm.asset"] != "app-1.0.0.tgz" {
+		t.Errorf("unexpected npm upload %v", fake.uploads[1])
+	}
+	want := map[string]string{"raw.directory": "/static/docs", "raw.asset1.filename": "index.html", "raw.asset1": "index.html"}
+	if !reflect.DeepEqual(fake.uploads[2], want) {
+		t.Errorf("raw upload got: %v want: %v", fake.uploads[2], want)
+	}
+
+	// a changed file is uploaded again
+	writeFiles(t, dir, map[string]string{"raw/docs/index.html": "changed"})
+	if err = client.applyUploads(c); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(fake.uploads) != 4 {
+		t.Errorf("uploads got: %v want: 4", len(fake.uploads))
+	}
+	if _, ok := fake.assets["npm-hosted"]["@tikal/app/-/app-1.0.0.tgz"]; !ok {
+		t.Errorf("npm assets got: %v", fake.assets["npm-hosted"])
+	}
+}
+
+func TestUploadSingleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"maven/io/tikal/app/1.0/app-1.0.pom": appPom,
+		"maven/io/tikal/app/1.0/app-1.0.jar": "jar",
+		"maven/io/tikal/lib/2.0/lib-2.0.jar": "lib",
+		"npm/app-1.0.0.tgz":                  npmTarball(t, "app", "1.0.0"),
+		"raw/index.html":                     "index",
+	})
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	fake := newFakeComponents(nexus)
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tests := []struct {
+		upload ArtifactoryUpload
+		want   map[string]string
+	}{
+		{ArtifactoryUpload{Repository: "releases", Path: filepath.Join(dir, "maven/io/tikal/app/1.0/app-1.0.pom")},
+			map[string]string{"maven2.asset1": "app-1.0.pom", "maven2.asset1.extension": "pom"}},
+		// the pom in the same directory is uploaded with the jar
+		{ArtifactoryUpload{Repository: "releases", Path: filepath.Join(dir, "maven/io/tikal/app/1.0/app-1.0.jar")},
+			map[string]string{"maven2.asset1": "app-1.0.jar", "maven2.asset1.extension": "jar",
+				"maven2.asset2": "app-1.0.pom", "maven2.asset2.extension": "pom"}},
+		{ArtifactoryUpload{Repository: "npm-hosted", Format: "npm", Path: filepath.Join(dir, "npm/app-1.0.0.tgz")},
+			map[string]string{"npm.asset": "app-1.0.0.tgz"}},
+		{ArtifactoryUpload{Repository: "site", Format: "raw", Path: filepath.Join(dir, "raw/index.html")},
+			map[string]string{"raw.directory": "/", "raw.asset1.filename": "index.html", "raw.asset1": "index.html"}},
+	}
+	for _, test := range tests {
+		fake.uploads = nil
+		err = client.applyUploads(ArtifactoryConfig{Uploads: []ArtifactoryUpload{test.upload}})
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.upload.Path, err)
+			continue
+		}
+		if len(fake.uploads) != 1 || !reflect.DeepEqual(fake.uploads[0], test.want) {
+			t.Errorf("%v: uploads got: %v want: %v", test.upload.Path, fake.uploads, test.want)
+		}
+	}
+
+	// a jar without pom doesn't have the groupId
+	jar := ArtifactoryUpload{Repository: "releases", Path: filepath.Join(dir, "maven/io/tikal/lib/2.0/lib-2.0.jar")}
+	if err = client.applyUploads(ArtifactoryConfig{Uploads: []ArtifactoryUpload{jar}}); err == nil {
+		t.Error("must return an error with a jar without pom")
+	}
+}
+
+func TestComponentExists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"app-1.0.jar": "jar"})
+	recorded, err := ioutil.ReadFile(filepath.Join("testdata", "rest", "search_assets.json"))
+	if err != nil {
+		t.Fatalf("reading fixture %v", err)
+	}
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	nexus.handle("/service/rest/v1/search/assets", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(recorded)
+	})
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the same checksum in a different path is not the same asset
+	tests := map[string]bool{
+		"io/tikal/app/1.0/app-1.0.jar":   true,
+		"io/tikal/copy/1.0/copy-1.0.jar": false,
+	}
+	for p, want := range tests {
+		comp := component{
+			Files: map[string]string{"maven2.asset1": filepath.Join(dir, "app-1.0.jar")},
+			Paths: map[string]string{"maven2.asset1": p},
+		}
+		got, err := client.componentExists("releases", comp)
+		if err != nil || got != want {
+			t.Errorf("%v: exists got: %v %v want: %v", p, got, err, want)
+		}
+	}
+}
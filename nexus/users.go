@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+// userStatus are the valid status of a user
+var userStatus = map[string]bool{"active": true, "disabled": true, "locked": true}
+
+// User represents a user in the nexus security API
+type User struct {
+	UserID        string   `json:"userId"`
+	FirstName     string   `json:"firstName"`
+	LastName      string   `json:"lastName"`
+	EmailAddress  string   `json:"emailAddress"`
+	Password      string   `json:"password,omitempty"`
+	Status        string   `json:"status"`
+	Roles         []string `json:"roles"`
+	Source        string   `json:"source,omitempty"`
+	ReadOnly      bool     `json:"readOnly,omitempty"`
+	ExternalRoles []string `json:"externalRoles,omitempty"`
+	// Version is only used by ExtDirect to update the user
+	Version string `json:"-"`
+}
+
+// extDirectUser represents a user in the coreui_User ExtDirect methods
+type extDirectUser struct {
+	UserID    string   `json:"userId"`
+	Version   string   `json:"version"`
+	FirstName string   `json:"firstName"`
+	LastName  string   `json:"lastName"`
+	Email     string   `json:"email"`
+	Status    string   `json:"status"`
+	Roles     []string `json:"roles"`
+	Password  string   `json:"password,omitempty"`
+}
+
+// UserCredentials represents the result of a user in the output file,
+// the password is only present when it was generated
+type UserCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	// Action is created or updated
+	Action string `json:"action"`
+}
+
+// validateUsers checks the usernames, emails and status of the users, the
+// users to create without password require the output file to write the
+// generated password, existing contains the users in nexus, nil when unknown
+func validateUsers(c ArtifactoryConfig, existing map[string]bool, outputFile string) error {
+	names := map[string]bool{}
+	for _, u := range c.Users {
+		if u.Username == "" {
+			return fmt.Errorf("user %v without username", u.Email)
+		}
+		if names[u.Username] {
+			return fmt.Errorf("user %v is defined more than once", u.Username)
+		}
+		names[u.Username] = true
+		if u.Email == "" {
+			return fmt.Errorf("user %v without email", u.Username)
+		}
+		if u.Status != "" && !userStatus[u.Status] {
+			return fmt.Errorf("user %v has an unknown status %v", u.Username, u.Status)
+		}
+		if u.Password == "" && u.NewPassword == "" && !existing[u.Username] && outputFile == "" {
+			return fmt.Errorf("user %v without password requires %v to write the generated password", u.Username, nexusOutputFileEnv)
+		}
+	}
+	return nil
+}
+
+// userFromConfig returns the user of the config, the names default to the username
+func userFromConfig(u ArtifactoryUser) User {
+	user := User{
+		UserID:       u.Username,
+		FirstName:    u.FirstName,
+		LastName:     u.LastName,
+		EmailAddress: u.Email,
+		Status:       u.Status,
+		Roles:        append([]string{}, u.Roles...),
+	}
+	if user.FirstName == "" {
+		user.FirstName = u.Username
+	}
+	if user.LastName == "" {
+		user.LastName = u.Username
+	}
+	if user.Status == "" {
+		user.Status = "active"
+	}
+	return user
+}
+
+// userTasks returns the tasks to create or update the users of the config and
+// calls saved with the credentials of each user as soon as it is saved, the calls
+// are serialized. The user of the client runs last because changing its password
+// changes the credentials of the client
+func userTasks(client *nexusClient, c ArtifactoryConfig, saved func(UserCredentials)) []*cms.Task {
+	var mu sync.Mutex
+	tasks := []*cms.Task{}
+	var self *cms.Task
+	for _, u := range c.Users {
+		u := u
+		t := cms.NewTask("user "+u.Username, func() error {
+			cred, err := client.saveUser(u)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			saved(cred)
+			mu.Unlock()
+			return nil
+		})
+		tasks = append(tasks, t)
+		if u.Username == client.user {
+			self = t
+		}
+	}
+	if self != nil {
+		for _, t := range tasks {
+			if t != self {
+				self.Deps = append(self.Deps, t)
+			}
+		}
+	}
+	return tasks
+}
+
+// saveUser creates the user or reconciles it when it already exists,
+// the password of an existing user only changes with NewPassword
+func (c *nexusClient) saveUser(u ArtifactoryUser) (UserCredentials, error) {
+	cred := UserCredentials{Username: u.Username}
+	user := userFromConfig(u)
+	existing, found, err := c.getUser(u.Username)
+	if err != nil {
+		return cred, err
+	}
+
+	if !found {
+		user.Password = u.Password
+		if user.Password == "" {
+			user.Password = u.NewPassword
+		}
+		if user.Password == "" {
+			user.Password, err = generatePassword()
+			if err != nil {
+				return cred, err
+			}
+			cred.Password = user.Password
+		}
+		err = c.createUser(user)
+		if err != nil {
+			return cred, err
+		}
+		log.Printf("user %v created", u.Username)
+		cred.Action = "created"
+		return cred, nil
+	}
+
+	user.Source = existing.Source
+	user.ReadOnly = existing.ReadOnly
+	user.ExternalRoles = existing.ExternalRoles
+	user.Version = existing.Version
+	err = c.updateUser(user)
+	if err != nil {
+		return cred, err
+	}
+	if u.NewPassword != "" {
+		err = c.changePassword(u.Username, u.NewPassword)
+		if err != nil {
+			return cred, err
+		}
+		log.Printf("user %v password changed", u.Username)
+	}
+	log.Printf("user %v updated", u.Username)
+	cred.Action = "updated"
+	return cred, nil
+}
+
+// users returns the ids of the users in nexus
+func (c *nexusClient) users() (map[string]bool, error) {
+	users := []User{}
+	if c.extDirect {
+		resp, err := c.call("coreui_User", "read")
+		if err != nil {
+			return nil, err
+		}
+		list := []extDirectUser{}
+		err = json.Unmarshal(resp.Result.Data, &list)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range list {
+			users = append(users, User{UserID: u.UserID})
+		}
+	} else {
+		err := c.do(http.MethodGet, "/v1/security/users", nil, &users)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ids := map[string]bool{}
+	for _, u := range users {
+		ids[u.UserID] = true
+	}
+	return ids, nil
+}
+
+// getUser returns the user with the id, found is false when the user doesn't exist
+func (c *nexusClient) getUser(id string) (user User, found bool, err error) {
+	if c.extDirect {
+		resp, err := c.call("coreui_User", "read")
+		if err != nil {
+			return user, false, err
+		}
+		users := []extDirectUser{}
+		err = json.Unmarshal(resp.Result.Data, &users)
+		if err != nil {
+			return user, false, err
+		}
+		for _, u := range users {
+			if u.UserID == id {
+				return User{UserID: u.UserID, Version: u.Version}, true, nil
+			}
+		}
+		return user, false, nil
+	}
+
+	// the userId query returns the users starting with the id
+	users := []User{}
+	err = c.do(http.MethodGet, "/v1/security/users?userId="+url.QueryEscape(id), nil, &users)
+	if err != nil {
+		return user, false, err
+	}
+	for _, u := range users {
+		if u.UserID == id {
+			return u, true, nil
+		}
+	}
+	return user, false, nil
+}
+
+// createUser creates a new user with its password
+func (c *nexusClient) createUser(u User) error {
+	if c.extDirect {
+		_, err := c.call("coreui_User", "create", toExtDirectUser(u))
+		return err
+	}
+	return c.do(http.MethodPost, "/v1/security/users", u, nil)
+}
+
+// updateUser replaces the details and roles of an existing user
+func (c *nexusClient) updateUser(u User) error {
+	if c.extDirect {
+		_, err := c.call("coreui_User", "update", toExtDirectUser(u))
+		return err
+	}
+	if u.Source == "" {
+		u.Source = "default"
+	}
+	return c.do(http.MethodPut, "/v1/security/users/"+url.PathEscape(u.UserID), u, nil)
+}
+
+// changePassword changes the password of a user, when the user is the user
+// of the client the client uses the new password
+func (c *nexusClient) changePassword(id, password string) error {
+	if c.extDirect {
+		return fmt.Errorf("changing the password of %v requires the REST API", id)
+	}
+	req, err := http.NewRequest(http.MethodPut,
+		c.host+"/service/rest/v1/security/users/"+url.PathEscape(id)+"/change-password", bytes.NewBufferString(password))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.user, c.pass)
+	req.Header.Add("Content-Type", "text/plain")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return &StatusError{Method: req.Method, URL: req.URL.String(), Code: resp.StatusCode, Body: "change password"}
+	}
+	if id == c.user {
+		c.pass = password
+	}
+	return nil
+}
+
+func toExtDirectUser(u User) extDirectUser {
+	return extDirectUser{
+		UserID:    u.UserID,
+		Version:   u.Version,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.EmailAddress,
+		Status:    u.Status,
+		Roles:     u.Roles,
+		Password:  u.Password,
+	}
+}
+
+// generatePassword returns a random password
+func generatePassword() (string, error) {
+	b := make([]byte, 18)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+var usersConfig = ArtifactoryConfig{
+	Users: []ArtifactoryUser{
+		{Username: "admin", Email: "admin@example.org", NewPassword: "rotated", Roles: []string{"nx-admin"}},
+		{Username: "ci", Email: "ci@example.org", Roles: []string{"deployer"}},
+		{Username: "jdoe", Email: "jdoe@example.org", FirstName: "John", LastName: "Doe", Password: "secret", Status: "disabled"},
+	},
+}
+
+func TestValidateUsers(t *testing.T) {
+	tests := map[string][]ArtifactoryUser{
+		"without username": {{Email: "ci@example.org"}},
+		"without email":    {{Username: "ci"}},
+		"duplicated":       {{Username: "ci", Email: "ci@example.org"}, {Username: "ci", Email: "ci@example.org"}},
+		"unknown status":   {{Username: "ci", Email: "ci@example.org", Status: "enabled"}},
+	}
+	for name, users := range tests {
+		if validateUsers(ArtifactoryConfig{Users: users}, nil, "report.json") == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+	if err := validateUsers(usersConfig, nil, "report.json"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// the generated password of a new user is lost without output file
+	if err := validateUsers(usersConfig, nil, ""); err == nil {
+		t.Error("must return an error with a new user without password and output file")
+	}
+	if err := validateUsers(usersConfig, map[string]bool{"ci": true}, ""); err != nil {
+		t.Errorf("existing user without password unexpected error %v", err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	for _, server := range []string{"Nexus/3.21.1-01 (OSS)", "Nexus/3.12.0-01 (OSS)"} {
+		nexus := newFakeNexus(server)
+		nexus.users["ci"] = User{UserID: "ci", Source: "default", Status: "active"}
+		srv := httptest.NewServer(nexus)
+		client, err := newNexusClient("admin", "admin123", srv.URL)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", server, err)
+		}
+		users, err := client.users()
+		if err != nil || len(users) != 1 || !users["ci"] {
+			t.Errorf("%v: users got: %v %v", server, users, err)
+		}
+		srv.Close()
+	}
+}
+
+func TestUserTasks(t *testing.T) {
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	nexus.users["admin"] = User{UserID: "admin", Source: "default", Status: "active"}
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	creds := []UserCredentials{}
+	collect := func(c UserCredentials) { creds = append(creds, c) }
+	tasks := userTasks(client, usersConfig, collect)
+	if len(tasks[0].Deps) != 2 {
+		t.Error("the user of the client must run last")
+	}
+	cms.RunTasks(tasks, 3)
+	for _, task := range tasks {
+		if task.Err != nil {
+			t.Errorf("unexpected error in %v: %v", task.Name, task.Err)
+		}
+	}
+
+	generated := map[string]UserCredentials{}
+	for _, c := range creds {
+		generated[c.Username] = c
+	}
+	if generated["admin"].Action != "updated" || generated["admin"].Password != "" {
+		t.Errorf("admin must be updated without password got: %+v", generated["admin"])
+	}
+	if generated["ci"].Action != "created" || len(generated["ci"].Password) != 24 || nexus.passwords["ci"] != generated["ci"].Password {
+		t.Errorf("ci must be created with a generated password got: %+v", generated["ci"])
+	}
+	if generated["jdoe"].Password != "" || nexus.passwords["jdoe"] != "secret" || nexus.users["jdoe"].Status != "disabled" {
+		t.Errorf("jdoe must be created with its password got: %+v", generated["jdoe"])
+	}
+	if nexus.passwords["admin"] != "rotated" || client.pass != "rotated" {
+		t.Errorf("admin password must be changed got: %v", nexus.passwords["admin"])
+	}
+
+	// a second run keeps the passwords
+	ciPassword := nexus.passwords["ci"]
+	creds = []UserCredentials{}
+	cms.RunTasks(userTasks(client, usersConfig, collect), 3)
+	if nexus.passwords["ci"] != ciPassword {
+		t.Error("the password of an existing user must not change")
+	}
+	for _, c := range creds {
+		if c.Action != "updated" || c.Password != "" {
+			t.Errorf("user must be updated got: %+v", c)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outputFile := filepath.Join(dir, "report.json")
+
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// each saved user is in the file before the next one is saved
+	report := Report{Users: []UserCredentials{}}
+	config := ArtifactoryConfig{Users: usersConfig.Users[1:]}
+	cms.RunTasks(userTasks(client, config, func(cred UserCredentials) {
+		report.Users = append(report.Users, cred)
+		if err := writeReport(outputFile, report); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		written := Report{}
+		if err := cms.DecodeFromFile(outputFile, &written); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(written.Users) != len(report.Users) {
+			t.Errorf("users in the file got: %v want: %v", len(written.Users), len(report.Users))
+		}
+	}), 1)
+	if len(report.Users) != 2 {
+		t.Fatalf("users got: %v want: 2", len(report.Users))
+	}
+
+	report.ClientConfig = &ClientConfig{MavenURL: "http://nexus:8081/repository/maven-public/"}
+	if err = writeReport(outputFile, report); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	body, err := ioutil.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]json.RawMessage{}
+	if err = json.Unmarshal(body, &keys); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := keys["client_config"]; !ok {
+		t.Errorf("client_config must be in the report got: %s", body)
+	}
+
+	if err = writeReport("", report); err != nil {
+		t.Errorf("an empty output file must not be written got: %v", err)
+	}
+}
+
+func TestUserTasksExtDirect(t *testing.T) {
+	nexus := newFakeNexus("Nexus/3.12.0-01 (OSS)")
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	config := ArtifactoryConfig{Users: usersConfig.Users[1:]}
+	for i := 0; i < 2; i++ {
+		tasks := userTasks(client, config, func(UserCredentials) {})
+		cms.RunTasks(tasks, 2)
+		for _, task := range tasks {
+			if task.Err != nil {
+				t.Errorf("unexpected error in %v: %v", task.Name, task.Err)
+			}
+		}
+	}
+	if len(nexus.users) != 2 || nexus.users["jdoe"].FirstName != "John" {
+		t.Errorf("users got: %+v", nexus.users)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import "fmt"
+
+// writePolicies are the valid write policies of the hosted repositories
+var writePolicies = map[string]bool{"ALLOW": true, "ALLOW_ONCE": true, "DENY": true}
+
+// validateHosteds checks the write policy and content disposition of the hosted repositories
+func validateHosteds(c ArtifactoryConfig) error {
+	for _, h := range c.Hosteds {
+		if h.WritePolicy != "" && !writePolicies[h.WritePolicy] {
+			return fmt.Errorf("hosted %v has an unknown writePolicy %v", h.Name, h.WritePolicy)
+		}
+		if h.ContentDisposition == "" {
+			continue
+		}
+		if repositoryFormat(h.Format) != formatMaven {
+			return fmt.Errorf("hosted %v contentDisposition is only valid for maven2 repositories", h.Name)
+		}
+		if h.ContentDisposition != "INLINE" && h.ContentDisposition != "ATTACHMENT" {
+			return fmt.Errorf("hosted %v has an unknown contentDisposition %v", h.Name, h.ContentDisposition)
+		}
+	}
+	return nil
+}
+
+// configWarnings returns the settings of the config that are valid but probably wrong
+func configWarnings(c ArtifactoryConfig) []string {
+	warnings := []string{}
+	for _, h := range c.Hosteds {
+		policy := h.WritePolicy
+		if policy == "" {
+			policy = "ALLOW"
+		}
+		if h.VersionPolicy == "RELEASE" && policy == "ALLOW" {
+			warnings = append(warnings, fmt.Sprintf("hosted %v with RELEASE versionPolicy allows to overwrite published artifacts, use ALLOW_ONCE writePolicy", h.Name))
+		}
+	}
+	return warnings
+}
+
+// validateProxies checks the names, remote urls and connection settings of the proxies
+func validateProxies(c ArtifactoryConfig) error {
+	for _, p := range c.Proxies {
+		if p.RemoteURL == "" {
+			return fmt.Errorf("proxy %v without remoteUrl", p.Name)
+		}
+		if err := validateAuthentication(p); err != nil {
+			return err
+		}
+		if p.Timeout < 0 || p.Timeout > 3600 {
+			return fmt.Errorf("proxy %v timeout must be between 1 and 3600 seconds", p.Name)
+		}
+		if p.Retries != nil && (*p.Retries < 0 || *p.Retries > 10) {
+			return fmt.Errorf("proxy %v retries must be between 0 and 10", p.Name)
+		}
+		if p.NegativeCacheTTL != nil && *p.NegativeCacheTTL < 0 {
+			return fmt.Errorf("proxy %v negativeCacheTTL must be positive", p.Name)
+		}
+		if p.MetadataMaxAge != nil && *p.MetadataMaxAge < -1 || p.ContentMaxAge != nil && *p.ContentMaxAge < -1 {
+			return fmt.Errorf("proxy %v max ages must be -1 or positive", p.Name)
+		}
+	}
+	return nil
+}
+
+// validateAuthentication checks the authentication of a proxy with requiredAuth
+func validateAuthentication(p ArtifactoryProxy) error {
+	if !p.RequiredAuth {
+		return nil
+	}
+	a := p.Authentication
+	if a == nil {
+		return fmt.Errorf("proxy %v with requiredAuth without authentication", p.Name)
+	}
+	if a.Password == redacted || a.BearerToken == redacted {
+		return fmt.Errorf("proxy %v authentication has the %v placeholder of the export, replace it with the credential", p.Name, redacted)
+	}
+	switch a.Type {
+	case "", "username":
+		if a.Username == "" || a.Password == "" {
+			return fmt.Errorf("proxy %v username authentication requires username and password", p.Name)
+		}
+	case "ntlm":
+		if a.Username == "" || a.Password == "" || a.NtlmHost == "" || a.NtlmDomain == "" {
+			return fmt.Errorf("proxy %v ntlm authentication requires username, password, ntlmHost and ntlmDomain", p.Name)
+		}
+	case "bearerToken":
+		if a.BearerToken == "" {
+			return fmt.Errorf("proxy %v bearerToken authentication requires bearerToken", p.Name)
+		}
+	default:
+		return fmt.Errorf("proxy %v has an unknown authentication type %v", p.Name, a.Type)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestValidateProxies(t *testing.T) {
+	negative, many := -2, 11
+	tests := map[string]ArtifactoryProxy{
+		"without url":  {Name: "a"},
+		"timeout":      {Name: "a", RemoteURL: "http://a", Timeout: 3601},
+		"retries":      {Name: "a", RemoteURL: "http://a", Retries: &many},
+		"negative ttl": {Name: "a", RemoteURL: "http://a", NegativeCacheTTL: &negative},
+		"content age":  {Name: "a", RemoteURL: "http://a", ContentMaxAge: &negative},
+		"metadata age": {Name: "a", RemoteURL: "http://a", MetadataMaxAge: &negative},
+	}
+	auth := map[string]*ArtifactoryAuth{
+		"without auth":     nil,
+		"without password": {Username: "user"},
+		"ntlm":             {Type: "ntlm", Username: "user", Password: "pass"},
+		"bearer token":     {Type: "bearerToken"},
+		"unknown type":     {Type: "digest", Username: "user", Password: "pass"},
+		"redacted token":   {Type: "bearerToken", BearerToken: redacted},
+	}
+	for name, a := range auth {
+		tests[name] = ArtifactoryProxy{Name: "a", RemoteURL: "http://a", RequiredAuth: true, Authentication: a}
+	}
+	for name, p := range tests {
+		if validateProxies(ArtifactoryConfig{Proxies: []ArtifactoryProxy{p}}) == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+}
+
+func TestValidateHosteds(t *testing.T) {
+	tests := map[string]ArtifactoryHosted{
+		"write policy":    {Name: "a", WritePolicy: "ONCE"},
+		"disposition":     {Name: "a", ContentDisposition: "DOWNLOAD"},
+		"raw disposition": {Name: "a", Format: "raw", ContentDisposition: "INLINE"},
+	}
+	for name, h := range tests {
+		if validateHosteds(ArtifactoryConfig{Hosteds: []ArtifactoryHosted{h}}) == nil {
+			t.Errorf("%v: must return an error", name)
+		}
+	}
+	valid := ArtifactoryHosted{Name: "a", WritePolicy: "ALLOW_ONCE", ContentDisposition: "INLINE"}
+	if err := validateHosteds(ArtifactoryConfig{Hosteds: []ArtifactoryHosted{valid}}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestConfigWarnings(t *testing.T) {
+	config := ArtifactoryConfig{Hosteds: []ArtifactoryHosted{
+		{Name: "releases", VersionPolicy: "RELEASE"},
+		{Name: "explicit", VersionPolicy: "RELEASE", WritePolicy: "ALLOW"},
+		{Name: "once", VersionPolicy: "RELEASE", WritePolicy: "ALLOW_ONCE"},
+		{Name: "snapshots", VersionPolicy: "SNAPSHOT"},
+	}}
+	warnings := configWarnings(config)
+	if len(warnings) != 2 {
+		t.Errorf("warnings got: %v", warnings)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	cms "github.com/xumak-grid/init-containers/pkg/commons"
+)
+
+const (
+	verifyOK      = "ok"
+	verifyFailed  = "failed"
+	verifyBlocked = "blocked"
+	verifySkipped = "skipped"
+)
+
+// defaultMavenProbe is the coordinate requested in maven2 repositories without a probe
+const defaultMavenProbe = "junit:junit"
+
+// VerifyResult represents the result of the probe of a repository in the report
+type VerifyResult struct {
+	Repository string `json:"repository"`
+	Type       string `json:"type"`
+	URL        string `json:"url,omitempty"`
+	// Status is ok, failed, blocked or skipped
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// verifyTarget is a repository of the config to probe
+type verifyTarget struct {
+	name, typ, format string
+}
+
+// repositoryStatus represents the status of a repository in coreui_Repository readStatus
+type repositoryStatus struct {
+	RepositoryName string `json:"repositoryName"`
+	Online         bool   `json:"online"`
+	Description    string `json:"description"`
+	Reason         string `json:"reason"`
+}
+
+// validateVerify checks the timeout and the probes
+func validateVerify(c ArtifactoryConfig) error {
+	v := c.Verify
+	if v == nil {
+		return nil
+	}
+	if v.Timeout < 0 {
+		return fmt.Errorf("verify has a negative timeout %v", v.Timeout)
+	}
+	for key, probe := range v.Probes {
+		if strings.TrimLeft(probe, "/") == "" {
+			return fmt.Errorf("verify probe %v is empty", key)
+		}
+	}
+	return nil
+}
+
+// probePath returns the path requested in the repository, empty when it doesn't have a probe
+func probePath(v *ArtifactoryVerify, name, format string) string {
+	probe, ok := v.Probes[name]
+	if !ok {
+		probe, ok = v.Probes[format]
+	}
+	if !ok && format == formatMaven {
+		probe = defaultMavenProbe
+	}
+	if format == formatMaven && strings.Count(probe, ":") == 1 && !strings.Contains(probe, "/") {
+		parts := strings.Split(probe, ":")
+		return strings.Replace(parts[0], ".", "/", -1) + "/" + parts[1] + "/maven-metadata.xml"
+	}
+	return strings.TrimLeft(probe, "/")
+}
+
+// verifyRepositories probes the proxies and groups of the config, the status
+// is read after the probes because nexus blocks a proxy when its remote fails,
+// the proxies auto-blocked by nexus are listed as blocked instead of failed
+func (c *nexusClient) verifyRepositories(cfg ArtifactoryConfig, concurrency int) ([]VerifyResult, error) {
+	v := cfg.Verify
+	timeout := v.Timeout
+	if timeout == 0 {
+		timeout = 30
+	}
+	client := cms.GetClient(timeout)
+
+	repos := []verifyTarget{}
+	for _, p := range cfg.Proxies {
+		repos = append(repos, verifyTarget{p.Name, typeProxy, repositoryFormat(p.Format)})
+	}
+	for _, g := range cfg.Groups {
+		repos = append(repos, verifyTarget{g.Name, typeGroup, repositoryFormat(g.Format)})
+	}
+	results := make([]VerifyResult, len(repos))
+	tasks := []*cms.Task{}
+	for i, repo := range repos {
+		r := &results[i]
+		r.Repository, r.Type = repo.name, repo.typ
+		path := probePath(v, repo.name, repo.format)
+		if path == "" {
+			r.Status, r.Message = verifySkipped, "without probe for "+repo.format
+			continue
+		}
+		r.URL = c.host + "/repository/" + repo.name + "/" + path
+		tasks = append(tasks, cms.NewTask(repo.name, func() error {
+			r.Status = verifyOK
+			if err := c.probe(client, r.URL); err != nil {
+				r.Status, r.Message = verifyFailed, err.Error()
+			}
+			return nil
+		}))
+	}
+	cms.RunTasks(tasks, concurrency)
+
+	blocked, err := c.blockedRepositories()
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		r := &results[i]
+		if reason, ok := blocked[r.Repository]; ok && r.Type == typeProxy && r.Status != verifyOK {
+			r.Status, r.Message = verifyBlocked, reason
+		}
+	}
+	return results, nil
+}
+
+// blockedRepositories returns the reason of each auto-blocked proxy by name
+func (c *nexusClient) blockedRepositories() (map[string]string, error) {
+	resp, err := c.call("coreui_Repository", "readStatus", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	status := []repositoryStatus{}
+	err = json.Unmarshal(resp.Result.Data, &status)
+	if err != nil {
+		return nil, err
+	}
+	blocked := map[string]string{}
+	for _, s := range status {
+		if strings.Contains(strings.ToLower(s.Description), "auto blocked") {
+			reason := s.Description
+			if s.Reason != "" {
+				reason += ": " + s.Reason
+			}
+			blocked[s.RepositoryName] = reason
+		}
+	}
+	return blocked, nil
+}
+
+// probe requests the URL and returns an error when it doesn't return content
+func (c *nexusClient) probe(client *http.Client, url string) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.user, c.pass)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %v", resp.StatusCode)
+	}
+	n, err := io.CopyN(ioutil.Discard, resp.Body, 1)
+	if n == 0 {
+		return fmt.Errorf("empty content %v", err)
+	}
+	return nil
+}
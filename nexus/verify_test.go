@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProbePath(t *testing.T) {
+	v := &ArtifactoryVerify{Probes: map[string]string{
+		"npm":        "left-pad",
+		"central":    "org.apache.maven:maven-core",
+		"thirdparty": "/com/example/lib/1.0/lib-1.0.pom",
+	}}
+	tests := []struct {
+		name, format, want string
+	}{
+		{"central", formatMaven, "org/apache/maven/maven-core/maven-metadata.xml"},
+		{"thirdparty", formatMaven, "com/example/lib/1.0/lib-1.0.pom"},
+		{"public", formatMaven, "junit/junit/maven-metadata.xml"},
+		{"npm-registry", "npm", "left-pad"},
+		{"docker-hub", "docker", ""},
+	}
+	for _, test := range tests {
+		if got := probePath(v, test.name, test.format); got != test.want {
+			t.Errorf("%v probe got: %v want: %v", test.name, got, test.want)
+		}
+	}
+
+	if err := validateVerify(ArtifactoryConfig{Verify: v}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	invalid := []*ArtifactoryVerify{
+		{Timeout: -1},
+		{Probes: map[string]string{"npm": "/"}},
+	}
+	for _, v := range invalid {
+		if err := validateVerify(ArtifactoryConfig{Verify: v}); err == nil {
+			t.Errorf("verify %v must be invalid", v)
+		}
+	}
+}
+
+// fakeContent serves the content of the repositories of a fakeNexus and their
+// status, a missing path in a repository of blockOnMiss blocks the repository
+type fakeContent struct {
+	mu          sync.Mutex
+	content     map[string]string
+	status      map[string]repositoryStatus
+	blockOnMiss map[string]string
+}
+
+func newFakeContent(f *fakeNexus) *fakeContent {
+	c := &fakeContent{content: map[string]string{}, status: map[string]repositoryStatus{}, blockOnMiss: map[string]string{}}
+	f.handle("/repository/", c.get)
+	f.handleAction("coreui_Repository.readStatus", c.readStatus)
+	return c
+}
+
+func (c *fakeContent) get(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.content[r.URL.Path]
+	if !ok {
+		repo := strings.Split(r.URL.Path, "/")[2]
+		if reason, block := c.blockOnMiss[repo]; block {
+			c.status[repo] = repositoryStatus{RepositoryName: repo, Online: true, Description: "Remote Auto Blocked and Unavailable", Reason: reason}
+		}
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprint(w, content)
+}
+
+func (c *fakeContent) readStatus(method string, data []json.RawMessage) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := []repositoryStatus{}
+	for _, s := range c.status {
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func TestVerifyRepositories(t *testing.T) {
+	nexus := newFakeNexus("Nexus/3.21.1-01 (OSS)")
+	fake := newFakeContent(nexus)
+	fake.content["/repository/central/junit/junit/maven-metadata.xml"] = "<metadata/>"
+	fake.content["/repository/public/junit/junit/maven-metadata.xml"] = "<metadata/>"
+	fake.content["/repository/mirror/junit/junit/maven-metadata.xml"] = ""
+	fake.status["central"] = repositoryStatus{RepositoryName: "central", Online: true, Description: "Remote Available"}
+	fake.status["blocked"] = repositoryStatus{RepositoryName: "blocked", Online: true,
+		Description: "Remote Auto Blocked and Unavailable", Reason: "java.net.UnknownHostException"}
+	// a new proxy is blocked by its failed probe
+	fake.blockOnMiss["fresh"] = "java.net.ConnectException"
+	srv := httptest.NewServer(nexus)
+	defer srv.Close()
+	client, err := newNexusClient("admin", "admin123", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	c := ArtifactoryConfig{
+		Proxies: []ArtifactoryProxy{{Name: "central"}, {Name: "mirror"}, {Name: "broken"}, {Name: "blocked"}, {Name: "hub", Format: "docker"}, {Name: "fresh"}},
+		Groups:  []ArtifactoryGroup{{Name: "public"}},
+		Verify:  &ArtifactoryVerify{Timeout: 5},
+	}
+	results, err := client.verifyRepositories(c, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got := map[string]string{}
+	for _, r := range results {
+		got[r.Repository] = r.Status
+	}
+	want := map[string]string{
+		"central": verifyOK,
+		"mirror":  verifyFailed,
+		"broken":  verifyFailed,
+		"blocked": verifyBlocked,
+		"hub":     verifySkipped,
+		"fresh":   verifyBlocked,
+		"public":  verifyOK,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("status got: %v want: %v", got, want)
+	}
+	if results[3].Message != "Remote Auto Blocked and Unavailable: java.net.UnknownHostException" {
+		t.Errorf("blocked message got: %v", results[3].Message)
+	}
+	if results[5].Message != "Remote Auto Blocked and Unavailable: java.net.ConnectException" {
+		t.Errorf("fresh message got: %v", results[5].Message)
+	}
+	if results[0].URL != srv.URL+"/repository/central/junit/junit/maven-metadata.xml" {
+		t.Errorf("url got: %v", results[0].URL)
+	}
+	bodies := nexus.bodies("coreui_Repository.readStatus")
+	if len(bodies) != 1 {
+		t.Fatalf("requests got: %v", bodies)
+	}
+	assertPayload(t, "extdirect/repository_read_status.json", bodies[0])
+}
@@ -5,8 +5,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
+
+	"sigs.k8s.io/yaml"
 )
 
 // GetEnv returns the environment variable value or using a default value if it is not present
@@ -25,9 +29,16 @@ func GetClient(seconds int) *http.Client {
 	}
 }
 
-// DecodeFromFile decodes the content of the file into given obj
-// the obj must be a pointer
+// DecodeFromFile decodes the content of the file into given obj, files
+// with the .yaml or .yml extension are decoded as yaml, the obj must be a pointer
 func DecodeFromFile(path string, obj interface{}) error {
+	if isYAML(path) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(data, obj)
+	}
 	data, err := os.Open(path)
 	if err != nil {
 		return err
@@ -40,6 +51,30 @@ func DecodeFromFile(path string, obj interface{}) error {
 	return nil
 }
 
+// EncodeToFile encodes the obj as indented json or as yaml when the file has
+// the .yaml or .yml extension, the file is created with the given permissions
+// or truncated if it exists
+func EncodeToFile(path string, obj interface{}, perm os.FileMode) error {
+	if isYAML(path) {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, data, perm)
+	}
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), perm)
+}
+
+// isYAML returns true when the file has the .yaml or .yml extension
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
 // ReplaceStr text in a file
 func ReplaceStr(path, old, new string) error {
 	read, err := ioutil.ReadFile(path)
@@ -54,3 +89,19 @@ func ReplaceStr(path, old, new string) error {
 	}
 	return nil
 }
+
+// ReplaceRegex replaces the matches of a regular expression in a file,
+// new can contain $1 or ${name} expansions
+func ReplaceRegex(path string, re *regexp.Regexp, new string) error {
+	read, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	content := re.ReplaceAll(read, []byte(new))
+	err = ioutil.WriteFile(path, content, 0)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -74,3 +76,88 @@ func TestDecodeFromFile(t *testing.T) {
 		t.Error("must return a error when file doesn't exist")
 	}
 }
+
+func TestEncodeToFile(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "test.json")
+	if err != nil {
+		t.Error("not possible to create file")
+		return
+	}
+	defer cleanUp(tmpFile)
+
+	err = EncodeToFile(tmpFile.Name(), &MyType{Name: "test"}, 0600)
+	if err != nil {
+		t.Errorf("not possible to encode MyType %v", err)
+		return
+	}
+	myType := MyType{}
+	err = DecodeFromFile(tmpFile.Name(), &myType)
+	if err != nil || myType.Name != "test" {
+		t.Errorf("decoded myType.Name contains %v and want: test %v", myType.Name, err)
+	}
+
+	err = EncodeToFile(tmpFile.Name(), make(chan int), 0600)
+	if err == nil {
+		t.Error("must return a error when the obj can't be encoded")
+	}
+}
+
+func TestEncodeToYAMLFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yaml")
+	if err != nil {
+		t.Error("not possible to create dir")
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.yaml")
+	err = EncodeToFile(path, &MyType{Name: "test", Description: "yaml"}, 0600)
+	if err != nil {
+		t.Errorf("not possible to encode MyType %v", err)
+		return
+	}
+	content, _ := ioutil.ReadFile(path)
+	if string(content) != "Description: yaml\nName: test\n" {
+		t.Errorf("encoded yaml got: %q", content)
+	}
+	myType := MyType{}
+	err = DecodeFromFile(path, &myType)
+	if err != nil || myType.Name != "test" || myType.Description != "yaml" {
+		t.Errorf("decoded myType contains %v and want: test yaml %v", myType, err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "invalid.yml"), []byte("Name: [test"), 0600)
+	if err == nil {
+		err = DecodeFromFile(filepath.Join(dir, "invalid.yml"), &myType)
+	}
+	if err == nil {
+		t.Error("must return a error with invalid yaml")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tmpFile, err := ioutil.TempFile("", "settings.xml")
+	if err != nil {
+		t.Error("not possible to create file")
+		return
+	}
+	defer cleanUp(tmpFile)
+	tmpFile.WriteString("<url>PROJECT_URL</url><version>1.0.0</version>")
+
+	err = ReplaceStr(tmpFile.Name(), "PROJECT_URL", "http://nexus")
+	if err != nil {
+		t.Errorf("not possible to replace string %v", err)
+		return
+	}
+	err = ReplaceRegex(tmpFile.Name(), regexp.MustCompile(`<version>(\d+)\.\d+\.\d+</version>`), "<version>$1.1.0</version>")
+	if err != nil {
+		t.Errorf("not possible to replace regex %v", err)
+		return
+	}
+
+	content, _ := ioutil.ReadFile(tmpFile.Name())
+	want := "<url>http://nexus</url><version>1.1.0</version>"
+	if string(content) != want {
+		t.Errorf("file contains %s and want: %v", content, want)
+	}
+}
@@ -0,0 +1,58 @@
+package commons
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Task represents a unit of work executed by RunTasks
+type Task struct {
+	Name string
+	// Deps are the tasks that must finish successfully before this task starts,
+	// they must be part of the same RunTasks call
+	Deps []*Task
+	Run  func() error
+	// Err is the result of Run, it is available after RunTasks returns
+	Err  error
+	done chan struct{}
+}
+
+// NewTask returns a new task that runs after its dependencies
+func NewTask(name string, run func() error, deps ...*Task) *Task {
+	return &Task{
+		Name: name,
+		Deps: deps,
+		Run:  run,
+		done: make(chan struct{}),
+	}
+}
+
+// RunTasks runs the tasks with at most limit tasks running at the same time,
+// a task fails without running when any of its dependencies fails.
+// The dependencies must not contain cycles.
+func RunTasks(tasks []*Task, limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		wg.Add(1)
+		go func(t *Task) {
+			defer wg.Done()
+			defer close(t.done)
+			for _, d := range t.Deps {
+				<-d.done
+				if d.Err != nil {
+					t.Err = fmt.Errorf("%v was not run because %v failed", t.Name, d.Name)
+					return
+				}
+			}
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			t.Err = t.Run()
+		}(t)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,66 @@
+package commons
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunTasks(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	order := []string{}
+	work := func(name string) func() error {
+		return func() error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			running--
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	a := NewTask("a", work("a"))
+	b := NewTask("b", work("b"))
+	c := NewTask("c", work("c"))
+	group := NewTask("group", work("group"), a, b)
+	RunTasks([]*Task{group, a, b, c}, 2)
+
+	if maxRunning != 2 {
+		t.Errorf("tasks running at the same time got: %d want: 2", maxRunning)
+	}
+	index := map[string]int{}
+	for i, name := range order {
+		index[name] = i
+	}
+	if index["group"] < index["a"] || index["group"] < index["b"] {
+		t.Errorf("group must run after its dependencies got: %v", order)
+	}
+	for _, task := range []*Task{a, b, c, group} {
+		if task.Err != nil {
+			t.Errorf("unexpected error in %v: %v", task.Name, task.Err)
+		}
+	}
+}
+
+func TestRunTasksFailedDependency(t *testing.T) {
+	ran := false
+	failed := NewTask("failed", func() error { return errors.New("failed") })
+	dependent := NewTask("dependent", func() error { ran = true; return nil }, failed)
+	RunTasks([]*Task{dependent, failed}, 0)
+
+	if failed.Err == nil || dependent.Err == nil {
+		t.Errorf("both tasks must fail got: %v %v", failed.Err, dependent.Err)
+	}
+	if ran {
+		t.Error("dependent task must not run")
+	}
+}